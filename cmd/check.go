@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report links to files or headings that don't exist",
+	Long: `Report links to files or headings that don't exist.
+
+Every broken link is printed as "file:line:column: destination".
+Anchors of the links to notes (note.md#heading, [[note#Heading]]) are checked against the headings of the linked note.
+The command exits with a non-zero status if broken links are found, so it can be used in pre-commit hooks.
+An existing index is used, but it's saved only if --index is given, so no new files are left in the checked tree.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getConfig(cmd)
+		cfg.ReadOnlyIndex = !cmd.Flags().Changed("index")
+		s := syncer.NewSyncer(cfg, syncer.NewLogger(cfg, nil))
+		s.ProcessFiles()
+		broken := s.Check()
+		s.Close()
+
+		for _, link := range broken {
+			if link.Anchor == "" {
+				fmt.Printf("%s:%d:%d: broken link %q\n", link.Source, link.Line, link.Column, link.Dest)
+				continue
+			}
+			dest := link.Dest
+			if !strings.HasPrefix(dest, "#") {
+				dest += "#" + link.Anchor
+			}
+			fmt.Printf("%s:%d:%d: missing anchor %q\n", link.Source, link.Line, link.Column, dest)
+		}
+		if len(broken) > 0 {
+			fmt.Fprintf(os.Stderr, "%d broken links found\n", len(broken))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
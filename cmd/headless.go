@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	"github.com/flytaly/linksyncer/pkg/log"
+	linksyncer "github.com/flytaly/linksyncer/pkg/syncer"
+)
+
+// Output formats of the headless mode
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Exit codes of the headless mode
+const (
+	exitOK    = 0
+	exitError = 1 // the watcher couldn't start or stop
+)
+
+// shutdownTimeout is how long the current synchronization can take after the program was asked to stop
+const shutdownTimeout = 10 * time.Second
+
+// printer writes log records and planned changes to the output, one line per record
+type printer struct {
+	w      io.Writer
+	format string
+	mu     sync.Mutex
+}
+
+type jsonRecord struct {
+	Time   time.Time      `json:"time"`
+	Level  string         `json:"level"`
+	Msg    string         `json:"msg"`
+	Fields map[string]any `json:"fields,omitempty"`
+	Path   string         `json:"path,omitempty"`
+	Diff   string         `json:"diff,omitempty"`
+}
+
+func (p *printer) print(r jsonRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.format == OutputJSON {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(p.w, "%s\n", data)
+		return
+	}
+	msg := r.Msg
+	if len(r.Fields) > 0 {
+		msg += " " + log.FormatFields(r.Fields)
+	}
+	fmt.Fprintf(p.w, "%s %-7s %s\n", r.Time.Format(time.RFC3339), strings.ToUpper(r.Level), msg)
+	if r.Diff != "" {
+		fmt.Fprint(p.w, r.Diff)
+	}
+}
+
+func (p *printer) record(r log.Record) {
+	p.print(jsonRecord{Time: r.Ts, Level: r.Level.String(), Msg: r.Msg, Fields: r.Fields})
+}
+
+func (p *printer) infof(format string, v ...any) {
+	p.print(jsonRecord{Time: time.Now(), Level: log.Info.String(), Msg: fmt.Sprintf(format, v...)})
+}
+
+func (p *printer) errorf(format string, v ...any) {
+	p.print(jsonRecord{Time: time.Now(), Level: log.Error.String(), Msg: fmt.Sprintf(format, v...)})
+}
+
+// runHeadless watches the directory without the interface until SIGINT or SIGTERM is received.
+// It returns the exit code.
+func runHeadless(cfg syncer.ProgramCfg, out *printer) int {
+	if fi, err := os.Stat(cfg.Root); err != nil || !fi.IsDir() {
+		out.errorf("Couldn't watch %s: not a directory", cfg.Root)
+		return exitError
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	records := make(chan log.Record, 256)
+	s := syncer.NewSyncer(cfg, syncer.NewLogger(cfg, records))
+	duration := s.ProcessFiles()
+	out.infof("Watching %s: %d notes, %d linked files, processed in %s", cfg.Root, s.SourcesNum(), s.RefsNum(), duration)
+
+	go s.WatchEvents(func(moves map[string]string) {
+		s.Sync(moves)
+		if !cfg.DryRun {
+			return
+		}
+		for _, d := range s.TakeDiffs() {
+			out.print(jsonRecord{Time: time.Now(), Level: log.Info.String(), Msg: "Planned changes: " + d.Path, Path: d.Path, Diff: d.Diff})
+		}
+	})
+	go s.StartFileWatcher(cfg.Interval)
+
+	for {
+		select {
+		case r := <-records:
+			out.record(r)
+		case sig := <-signals:
+			out.infof("Received %s, stopping", sig)
+			return stopHeadless(s, records, out)
+		}
+	}
+}
+
+// stopHeadless waits for the current synchronization, saves the index and closes the watcher
+func stopHeadless(s *linksyncer.LinkSyncer, records chan log.Record, out *printer) int {
+	stopped := make(chan struct{})
+	go func() {
+		// the watcher is closed while events are still received, so a pending scan can finish,
+		// then the listeners are stopped before the index is saved and the log is closed
+		if err := s.Watcher.Close(); err != nil {
+			out.errorf("Couldn't close watcher: %s", err)
+		}
+		s.StopEventListeners()
+		s.Close()
+		close(stopped)
+	}()
+
+	timeout := time.After(shutdownTimeout)
+	for {
+		select {
+		case r := <-records:
+			out.record(r)
+		case <-stopped:
+			for {
+				select {
+				case r := <-records:
+					out.record(r)
+				default:
+					out.infof("Stopped")
+					return exitOK
+				}
+			}
+		case <-timeout:
+			out.errorf("Couldn't stop in %s", shutdownTimeout)
+			return exitError
+		}
+	}
+}
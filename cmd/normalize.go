@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	linksyncer "github.com/flytaly/linksyncer/pkg/syncer"
+	"github.com/spf13/cobra"
+)
+
+// normalizeCmd represents the normalize command
+var normalizeCmd = &cobra.Command{
+	Use:   "normalize",
+	Short: "Rewrite all links into the same style",
+	Long: `Rewrite links in all notes into the style given with --link-style (relative by default).
+
+Links that start with "/" are resolved against the watched directory.
+Use "--link-style root" for static site generators like Hugo that expect "/images/x.png",
+or "--link-style relative" for renderers that expect paths relative to the note, like GitHub.
+Wiki links and links to files outside of the watched directory aren't changed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getConfig(cmd)
+		if cfg.LinkStyle == linksyncer.LinkStyleKeep {
+			cfg.LinkStyle = linksyncer.LinkStyleRelative
+		}
+		s := syncer.NewSyncer(cfg, syncer.NewLogger(cfg, nil))
+		s.ProcessFiles()
+		rewritten := s.Normalize()
+		defer s.Close()
+
+		if !cfg.DryRun {
+			for _, path := range rewritten {
+				fmt.Println(path)
+			}
+			fmt.Fprintf(os.Stderr, "%d notes rewritten\n", len(rewritten))
+			return
+		}
+		diffs := []string{}
+		for _, d := range s.TakeDiffs() {
+			diffs = append(diffs, d.Diff)
+		}
+		if cfg.PatchPath == "" {
+			fmt.Print(strings.Join(diffs, ""))
+			return
+		}
+		if err := os.WriteFile(cfg.PatchPath, []byte(strings.Join(diffs, "")), 0644); err != nil {
+			fmt.Printf("Error: couldn't save patch: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Planned changes of %d notes saved to %s\n", len(diffs), cfg.PatchPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(normalizeCmd)
+}
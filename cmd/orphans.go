@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	"github.com/spf13/cobra"
+)
+
+// orphansCmd represents the orphans command
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List images that aren't referenced by any note",
+	Long: `List images that aren't referenced by any note.
+
+Orphaned images can be moved into a quarantine folder with --move-to (relative paths are preserved)
+or deleted with --delete. Both actions ask for confirmation unless --yes is given.
+With --dry-run the orphaned images are only listed.
+An existing index is used, but it's saved only if --index is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getConfig(cmd)
+		cfg.ReadOnlyIndex = !cmd.Flags().Changed("index")
+		moveTo, _ := cmd.Flags().GetString("move-to")
+		del, _ := cmd.Flags().GetBool("delete")
+		yes, _ := cmd.Flags().GetBool("yes")
+		if moveTo != "" && del {
+			fmt.Println("Error: --move-to and --delete can't be used together")
+			os.Exit(1)
+		}
+
+		s := syncer.NewSyncer(cfg, syncer.NewLogger(cfg, nil))
+		s.ProcessFiles()
+		orphans := s.Orphans()
+		defer s.Close()
+
+		for _, path := range orphans {
+			fmt.Println(path)
+		}
+		if len(orphans) == 0 || (moveTo == "" && !del) {
+			return
+		}
+		if cfg.DryRun {
+			if moveTo != "" {
+				fmt.Fprintf(os.Stderr, "Dry run: %d files would be moved to %s\n", len(orphans), moveTo)
+			} else {
+				fmt.Fprintf(os.Stderr, "Dry run: %d files would be deleted\n", len(orphans))
+			}
+			return
+		}
+
+		var err error
+		if moveTo != "" {
+			if !yes && !confirm(fmt.Sprintf("Move %d files to %s?", len(orphans), moveTo)) {
+				return
+			}
+			err = s.QuarantineFiles(orphans, moveTo)
+		} else {
+			if !yes && !confirm(fmt.Sprintf("Delete %d files?", len(orphans))) {
+				return
+			}
+			err = s.DeleteFiles(orphans)
+		}
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			s.Close() // deferred calls don't run on exit
+			os.Exit(1)
+		}
+	},
+}
+
+// confirm asks the user a yes/no question
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+
+	orphansCmd.Flags().String("move-to", "", "move orphaned images into the directory")
+	orphansCmd.Flags().Bool("delete", false, "delete orphaned images")
+	orphansCmd.Flags().BoolP("yes", "y", false, "don't ask for confirmation")
+}
@@ -3,32 +3,147 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	"github.com/flytaly/linksyncer/pkg/config"
+	"github.com/flytaly/linksyncer/pkg/log"
+	linksyncer "github.com/flytaly/linksyncer/pkg/syncer"
 	"github.com/spf13/cobra"
 )
 
 func getConfig(cmd *cobra.Command) syncer.ProgramCfg {
 	logPath, _ := cmd.Flags().GetString("log")
+	logFormat, _ := cmd.Flags().GetString("log-format")
+	logMaxSize, _ := cmd.Flags().GetInt64("log-max-size")
+	logMaxAge, _ := cmd.Flags().GetDuration("log-max-age")
+	logMaxFiles, _ := cmd.Flags().GetInt("log-max-files")
 	interval, _ := cmd.Flags().GetDuration("interval")
 	root, _ := cmd.Flags().GetString("path")
 	maxSizeInKb, _ := cmd.Flags().GetInt64("size")
+	linkable, _ := cmd.Flags().GetStringSlice("linkable")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	patchPath, _ := cmd.Flags().GetString("patch")
+	journalDir, _ := cmd.Flags().GetString("journal")
+	backups, _ := cmd.Flags().GetInt("backups")
+	backupDir, _ := cmd.Flags().GetString("backup-dir")
+	backend, _ := cmd.Flags().GetString("backend")
+	indexPath, _ := cmd.Flags().GetString("index")
+	rebuildIndex, _ := cmd.Flags().GetBool("rebuild-index")
+	workers, _ := cmd.Flags().GetInt("workers")
+	renames, _ := cmd.Flags().GetString("renames")
+	parsable, _ := cmd.Flags().GetStringSlice("parsable")
+	excluded, _ := cmd.Flags().GetStringSlice("exclude")
+	ignoreFiles, _ := cmd.Flags().GetStringSlice("ignore-files")
+	linkStyle, _ := cmd.Flags().GetString("link-style")
+	if backend != syncer.BackendPoll && backend != syncer.BackendInotify {
+		fmt.Printf("Error: unknown backend %q, use %q or %q\n", backend, syncer.BackendPoll, syncer.BackendInotify)
+		os.Exit(1)
+	}
+	if logFormat != log.FormatText && logFormat != log.FormatJSON {
+		fmt.Printf("Error: unknown log format %q, use %q or %q\n", logFormat, log.FormatText, log.FormatJSON)
+		os.Exit(1)
+	}
+	if logMaxSize < 0 || logMaxAge < 0 || logMaxFiles < 0 {
+		fmt.Println("Error: log rotation limits shouldn't be negative")
+		os.Exit(1)
+	}
+	if renames != syncer.RenamesByInode && renames != syncer.RenamesByHash {
+		fmt.Printf("Error: unknown rename detection %q, use %q or %q\n", renames, syncer.RenamesByInode, syncer.RenamesByHash)
+		os.Exit(1)
+	}
+	var err error
 	if root == "" {
-		var err error
 		root, err = os.Getwd()
-		if err != nil {
-			fmt.Printf("Error: %s", err)
-			os.Exit(1)
-		}
+	} else {
+		root, err = filepath.Abs(root)
 	}
+	if err != nil {
+		fmt.Printf("Error: %s", err)
+		os.Exit(1)
+	}
+
+	// settings from the configuration files are used unless the flags are set explicitly
+	fileCfg, err := loadConfigFile(cmd, root)
+	if err != nil {
+		fmt.Printf("Error: couldn't load configuration: %s\n", err)
+		os.Exit(1)
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("link-style") && fileCfg.LinkStyle != "" {
+		linkStyle = fileCfg.LinkStyle
+	}
+	if !isLinkStyle(linkStyle) {
+		fmt.Printf("Error: unknown link style %q, use %q, %q, %q or %q\n", linkStyle,
+			linksyncer.LinkStyleKeep, linksyncer.LinkStyleRelative, linksyncer.LinkStyleRoot, linksyncer.LinkStyleAbsolute)
+		os.Exit(1)
+	}
+	if !flags.Changed("log") && fileCfg.Log != "" {
+		logPath = fileCfg.Log
+	}
+	if !flags.Changed("interval") && fileCfg.Interval > 0 {
+		interval = fileCfg.Interval
+	}
+	if !flags.Changed("size") && fileCfg.MaxSize > 0 {
+		maxSizeInKb = fileCfg.MaxSize
+	}
+	if !flags.Changed("linkable") && fileCfg.Linkable != nil {
+		linkable = fileCfg.Linkable
+	}
+	if !flags.Changed("parsable") && fileCfg.Parsable != nil {
+		parsable = fileCfg.Parsable
+	}
+	if !flags.Changed("exclude") && fileCfg.ExcludeDirs != nil {
+		excluded = fileCfg.ExcludeDirs
+	}
+
 	return syncer.ProgramCfg{
 		Interval:    interval,
 		LogPath:     logPath,
+		LogFormat:   logFormat,
+		LogRotation: log.Rotation{MaxSize: logMaxSize * 1024 * 1024, MaxAge: logMaxAge, MaxFiles: logMaxFiles},
 		Root:        root,
 		MaxFileSize: maxSizeInKb * 1024,
+		Linkable:    linkable,
+		Parsable:    parsable,
+		Excluded:    excluded,
+		IgnoreFiles: ignoreFiles,
+		DryRun:      dryRun || patchPath != "",
+		PatchPath:   patchPath,
+		JournalDir:  journalDir,
+		Backups:     backups,
+		BackupDir:   backupDir,
+		Backend:     backend,
+		LinkStyle:   linkStyle,
+
+		IndexPath:    indexPath,
+		RebuildIndex: rebuildIndex,
+		Workers:      workers,
+		Renames:      renames,
 	}
 }
 
+// isLinkStyle reports whether the style is one of the supported link styles
+func isLinkStyle(style string) bool {
+	switch style {
+	case linksyncer.LinkStyleKeep, linksyncer.LinkStyleRelative, linksyncer.LinkStyleRoot, linksyncer.LinkStyleAbsolute:
+		return true
+	}
+	return false
+}
+
+// loadConfigFile reads the file given with the --config flag,
+// or the user's and the project's configuration files
+func loadConfigFile(cmd *cobra.Command, root string) (*config.Config, error) {
+	path, _ := cmd.Flags().GetString("config")
+	if path != "" {
+		return config.LoadFile(path)
+	}
+	return config.Load(root)
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "linksyncer",
@@ -39,7 +154,8 @@ Launch the program in the root directory that contains note files and images.
 
 Use the "watch" command to automatically monitor for changes. 
 
-The watcher internally polls the file system, so avoid using the automatic watch mode from the root of the file system or folders containing a very large number of files.
+By default, the watcher polls the file system, so avoid using the automatic watch mode from the root of the file system or folders containing a very large number of files.
+On Linux, use "--backend=inotify" to get changes from the kernel instead.
 `,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
@@ -68,12 +184,41 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.linksyncer.yaml)")
+	rootCmd.PersistentFlags().String("config", "", "path to the configuration file (default is "+config.ProjectFile+" in the watched directory and $XDG_CONFIG_HOME/linksyncer/config.yaml)")
 	rootCmd.PersistentFlags().StringP("path", "p", "", "path to the watched directory (default is the working directory)")
 	rootCmd.PersistentFlags().StringP("log", "l", "", "path to the log file")
+	rootCmd.PersistentFlags().String("log-format", log.FormatText, `format of the log file: "text" or "json" (one object per line)`)
+	rootCmd.PersistentFlags().Int64("log-max-size", 10, "maximum size of the log file in MB before it's rotated (0 to disable)")
+	rootCmd.PersistentFlags().Duration("log-max-age", 0, "maximum age of the log file before it's rotated, e.g. 24h (0 to disable)")
+	rootCmd.PersistentFlags().Int("log-max-files", 3, "number of rotated log files to keep")
 	rootCmd.PersistentFlags().Int64("size", 1024, "maximum file size in KB")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "don't modify files, print planned changes as a unified diff")
+	rootCmd.PersistentFlags().String("patch", "", "save planned changes to the patch file instead of printing them (implies --dry-run)")
+	rootCmd.PersistentFlags().String("journal", linksyncer.JournalDir, "directory for the journal of changes used by \"undo\", relative to the watched directory (empty to disable)")
+	rootCmd.PersistentFlags().Int("backups", 0, "number of copies kept for every rewritten note (0 to disable)")
+	rootCmd.PersistentFlags().String("backup-dir", linksyncer.BackupDir, `directory for copies of the rewritten notes, relative to the watched directory (empty to keep a ".bak" file next to the note)`)
+	rootCmd.PersistentFlags().String("index", linksyncer.IndexFile, "file for the index of parsed notes, relative to the watched directory (empty to disable)")
+	rootCmd.PersistentFlags().Bool("rebuild-index", false, "ignore the saved index and parse all notes")
+	rootCmd.PersistentFlags().Int("workers", 0, "number of notes parsed concurrently during indexing (0 to use the number of CPUs)")
+	rootCmd.PersistentFlags().String("renames", syncer.RenamesByInode, `how renamed files are detected: "inode" or "hash" (compare content, for network and FUSE file systems)`)
+	rootCmd.PersistentFlags().String("backend", syncer.BackendPoll, `file watcher backend: "poll" or "inotify" (Linux only)`)
+	rootCmd.PersistentFlags().StringSlice("linkable", strings.Split(linksyncer.ImgExtensions, "|"), `extensions of the linked files to track, "*" to track all files`)
+	rootCmd.PersistentFlags().StringSlice("parsable", []string{linksyncer.ParsableFilesExtension}, "extensions of the Markdown notes")
+	rootCmd.PersistentFlags().StringSlice("exclude", excludedDirs(), "names of the directories that aren't watched")
+	rootCmd.PersistentFlags().String("link-style", linksyncer.LinkStyleKeep, `style of the rewritten links: "keep", "relative", "root" (/path from the watched directory) or "absolute"`)
+	rootCmd.PersistentFlags().StringSlice("ignore-files", linksyncer.IgnoreFiles, `names of gitignore-style files with patterns of the paths that aren't watched (empty to disable)`)
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// excludedDirs returns sorted names of the directories excluded by default
+func excludedDirs() []string {
+	dirs := []string{}
+	for dir := range linksyncer.ExcludedDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -2,6 +2,7 @@ package teaprogram
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/gookit/color"
 )
@@ -26,6 +27,14 @@ func printMoves(moves map[string]string, limit int, maxWidth int) string {
 	return output
 }
 
+// printList joins the first items of the list and the number of the rest
+func printList(items []string, limit int) string {
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:limit], ", "), len(items)-limit)
+}
+
 func tail(s string, n int) string {
 	r := []rune(s)
 	if (len(r) - n) <= 0 {
@@ -33,3 +42,20 @@ func tail(s string, n int) string {
 	}
 	return "..." + string(r[len(r)-(n-3):])
 }
+
+func formatDiff(d string) string {
+	lines := strings.Split(strings.TrimSuffix(d, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+			lines[i] = color.Bold.Sprint(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = color.Cyan.Sprint(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = color.Green.Sprint(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = color.Red.Sprint(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -8,12 +8,14 @@ type keyMap struct {
 	Watch   key.Binding
 	Quit    key.Binding
 	Log     key.Binding
+	Undo    key.Binding
+	Retry   key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
 // of the key.Map interface.
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Watch, k.Log, k.Quit}
+	return []key.Binding{k.Watch, k.Undo, k.Retry, k.Log, k.Quit}
 }
 
 // FullHelp returns keybindings for the expanded help view. It's part of the
@@ -41,4 +43,12 @@ var keys = keyMap{
 		key.WithKeys("l"),
 		key.WithHelp("l", "toggle log"),
 	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "undo last sync"),
+	),
+	Retry: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "retry conflicts"),
+	),
 }
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/flytaly/linksyncer/pkg/fswatcher"
 	"github.com/flytaly/linksyncer/pkg/log"
 	linksyncer "github.com/flytaly/linksyncer/pkg/syncer"
 
@@ -48,6 +49,7 @@ type model struct {
 	status       Status
 	movesChan    chan movesMsg
 	moves        map[string]string
+	conflicts    []string // notes that weren't updated because they were modified
 
 	spinner spinner.Model
 
@@ -56,6 +58,10 @@ type model struct {
 	showLog bool
 
 	duration time.Duration
+
+	dryRun       bool
+	patchPath    string
+	patchCreated bool
 }
 
 // Init optionally returns an initial command we should run.
@@ -115,16 +121,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			if len(m.moves) != 0 {
 				m.syncer.Sync(m.moves)
+				m.conflicts = m.syncer.Conflicts()
 			}
 			m.status = Waiting
 			m.syncer.Scan()
-			return m, nil
+			return m, m.reportDiffs()
 		case key.Matches(msg, m.keys.Cancel):
 			if m.status == ShouldConfirm {
 				m.status = Waiting
 			}
 			m.syncer.Scan()
 			return m, nil
+		case key.Matches(msg, m.keys.Undo):
+			if m.dryRun || m.status == ShouldConfirm || m.status == Initial {
+				return m, nil
+			}
+			m.undo()
+			return m, nil
+		case key.Matches(msg, m.keys.Retry):
+			if len(m.conflicts) == 0 || m.status == ShouldConfirm {
+				return m, nil
+			}
+			m.syncer.RetryConflicts()
+			m.conflicts = m.syncer.Conflicts()
+			return m, nil
 		case key.Matches(msg, m.keys.Log):
 			m.showLog = !m.showLog
 			return m, nil
@@ -145,6 +165,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch m.status {
 		case Watching:
 			m.syncer.Sync(msg)
+			m.conflicts = m.syncer.Conflicts()
+			return m, tea.Batch(waitForMoves(m.movesChan), m.reportDiffs())
 		default:
 			if len(msg) != 0 {
 				m.status = ShouldConfirm
@@ -153,10 +175,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, waitForMoves(m.movesChan)
 	case log.Record:
-		if len(m.logs) >= logRowsTotal {
-			m.logs = m.logs[1:]
-		}
-		m.logs = append(m.logs, msg)
+		m.addLog(msg)
 		return m, waitForLogs(m.logCh)
 	case spinner.TickMsg:
 		if m.status != Watching && m.status != Initial {
@@ -169,10 +188,84 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// undo reverts the last synchronization and processes files again
+func (m *model) undo() {
+	watching := m.status == Watching
+	if watching {
+		m.syncer.StopFileWatcher()
+	}
+	batch, err := m.syncer.Undo("")
+	if err != nil {
+		m.addLog(log.Record{Level: log.Error, Ts: time.Now(), Msg: fmt.Sprintf("Couldn't undo: %s", err)})
+	} else {
+		m.addLog(log.Record{Level: log.Info, Ts: time.Now(), Msg: fmt.Sprintf("Batch %s reverted", batch.ID)})
+	}
+	m.duration = m.syncer.ProcessFiles()
+	if watching {
+		go m.syncer.StartFileWatcher(time.Millisecond * 500)
+	}
+}
+
+// reportDiffs prints changes planned in the dry-run mode or saves them to the patch file
+func (m *model) reportDiffs() tea.Cmd {
+	if !m.dryRun {
+		return nil
+	}
+	diffs := m.syncer.TakeDiffs()
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	if m.patchPath != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		if !m.patchCreated {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		}
+		err := appendPatch(m.patchPath, flags, diffs)
+		if err != nil {
+			m.addLog(log.Record{Level: log.Error, Ts: time.Now(), Msg: fmt.Sprintf("Couldn't save patch: %s", err)})
+			return nil
+		}
+		m.patchCreated = true
+		m.addLog(log.Record{Level: log.Info, Ts: time.Now(), Msg: fmt.Sprintf("Planned changes of %d files saved to %s", len(diffs), m.patchPath)})
+		return nil
+	}
+
+	cmds := []tea.Cmd{}
+	for _, d := range diffs {
+		cmds = append(cmds, tea.Println(formatDiff(d.Diff)))
+	}
+	return tea.Sequence(cmds...)
+}
+
+func appendPatch(path string, flags int, diffs []linksyncer.FileDiff) error {
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		if _, err = file.WriteString(d.Diff); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+func (m *model) addLog(r log.Record) {
+	if len(m.logs) >= logRowsTotal {
+		m.logs = m.logs[1:]
+	}
+	m.logs = append(m.logs, r)
+}
+
 // View returns a string based on data in the model. That string which will be
 // rendered to the terminal.
 func (m model) View() string {
 	result := fmt.Sprintf("Path %s", color.Cyan.Sprint(m.root))
+	if m.dryRun {
+		result += color.Yellow.Sprint(" [dry run]")
+	}
 
 	if m.status != Initial {
 		result += m.renderStats()
@@ -191,6 +284,10 @@ func (m model) View() string {
 		result += fmt.Sprintf("\n\n%s Press '%s' to check the path for changes", color.Green.Sprint("➜"), color.Green.Sprint("Enter"))
 	}
 
+	if len(m.conflicts) > 0 {
+		result += "\n" + m.renderConflicts()
+	}
+
 	helpView := m.help.View(m.keys)
 
 	result += "\n\n" + helpView
@@ -203,7 +300,7 @@ func (m model) View() string {
 }
 
 func (m model) renderStats() string {
-	result := logTextStyle.Render(fmt.Sprintf("\n%d source files. %d linked images.",
+	result := logTextStyle.Render(fmt.Sprintf("\n%d source files. %d linked files.",
 		m.syncer.SourcesNum(),
 		m.syncer.RefsNum(),
 	))
@@ -215,6 +312,12 @@ func (m model) renderStats() string {
 	return result + logTextStyle.Render(fmt.Sprintf("[%d ms]", m.duration.Milliseconds()))
 }
 
+func (m model) renderConflicts() string {
+	return logErrorStyle.Render(fmt.Sprintf("⚠ %d notes were modified during synchronization and weren't updated: %s",
+		len(m.conflicts), printList(m.conflicts, 3),
+	)) + fmt.Sprintf("\n%s Press '%s' to update them again", color.Green.Sprint("➜"), color.Green.Sprint("r"))
+}
+
 func (m model) renderLog() string {
 	result := logTitleStyle.Render("Log") + "\n"
 	offset := max(len(m.logs)-logRosShow, 0)
@@ -239,20 +342,108 @@ func (m model) renderLog() string {
 type ProgramCfg struct {
 	Interval    time.Duration
 	LogPath     string
+	LogFormat   string // format of the log file: "text" or "json"
+	LogRotation log.Rotation
 	Root        string
 	MaxFileSize int64
+	Linkable    []string
+	DryRun      bool
+	PatchPath   string
+	JournalDir  string
+	Backend     string // file watcher backend: "poll" or "inotify"
+	IndexPath   string
+	// RebuildIndex ignores the saved index
+	RebuildIndex bool
+	// ReadOnlyIndex loads the saved index without writing it
+	ReadOnlyIndex bool
+	Workers       int      // number of notes parsed concurrently, 0 to use the number of CPUs
+	Renames       string   // rename detection strategy: "inode" or "hash"
+	Parsable      []string // extensions of the notes
+	Excluded      []string // names of the excluded directories
+	IgnoreFiles   []string // names of gitignore-style files
+	Backups       int      // number of copies kept for every rewritten note
+	LinkStyle     string   // style of the rewritten links
+	BackupDir     string
 }
 
-func NewProgram(cfg ProgramCfg) *tea.Program {
-	logChannel := make(chan log.Record, logRowsTotal)
-	syncer := linksyncer.New(
-		os.DirFS(cfg.Root), cfg.Root, log.New(cfg.LogPath, logChannel),
+const (
+	BackendPoll    = "poll"
+	BackendInotify = "inotify"
+)
+
+const (
+	RenamesByInode = "inode"
+	RenamesByHash  = "hash"
+)
+
+// NewLogger creates a logger that writes to the log file in the configured format
+// and sends records to the channel
+func NewLogger(cfg ProgramCfg, channel chan log.Record) log.Logger {
+	if cfg.LogFormat == log.FormatJSON {
+		return log.NewJSON(cfg.LogPath, channel, cfg.LogRotation)
+	}
+	return log.New(cfg.LogPath, channel, cfg.LogRotation)
+}
+
+// NewSyncer creates LinkSyncer with the given configuration
+func NewSyncer(cfg ProgramCfg, logger log.Logger) *linksyncer.LinkSyncer {
+	return linksyncer.New(
+		os.DirFS(cfg.Root), cfg.Root, logger,
 		func(s *linksyncer.LinkSyncer) {
 			if cfg.MaxFileSize > 0 {
 				s.MaxFileSize = cfg.MaxFileSize
 			}
+			if len(cfg.Linkable) > 0 {
+				s.LinkableExtensions = cfg.Linkable
+			}
+			if len(cfg.Parsable) > 0 {
+				s.ParsableExtensions = cfg.Parsable
+			}
+			if cfg.IgnoreFiles != nil {
+				s.IgnoreFiles = cfg.IgnoreFiles
+			}
+			if cfg.Excluded != nil {
+				s.ExcludedDirs = map[string]bool{}
+				for _, dir := range cfg.Excluded {
+					s.ExcludedDirs[dir] = true
+				}
+			}
+			s.DryRun = cfg.DryRun
+			s.JournalDir = cfg.JournalDir
+			s.Backups = cfg.Backups
+			if cfg.LinkStyle != "" {
+				s.LinkStyle = cfg.LinkStyle
+			}
+			s.BackupDir = cfg.BackupDir
+			s.IndexPath = cfg.IndexPath
+			s.RebuildIndex = cfg.RebuildIndex
+			s.ReadOnlyIndex = cfg.ReadOnlyIndex
+			if cfg.Workers > 0 {
+				s.Workers = cfg.Workers
+			}
+			watcherOptions := []fswatcher.Option{}
+			if cfg.Renames == RenamesByHash {
+				watcherOptions = append(watcherOptions,
+					fswatcher.WithRenameDetection(fswatcher.RenameByHash),
+					fswatcher.WithHashFilter(s.Tracked),
+				)
+			}
+			if cfg.Backend == BackendInotify {
+				watcher, err := fswatcher.NewInotify(os.DirFS(cfg.Root), cfg.Root, watcherOptions...)
+				if err == nil {
+					s.Watcher = watcher
+					return
+				}
+				logger.Warning("Couldn't use inotify, falling back to polling: %s", err)
+			}
+			s.Watcher = fswatcher.NewFsPoller(os.DirFS(cfg.Root), cfg.Root, watcherOptions...)
 		},
 	)
+}
+
+func NewProgram(cfg ProgramCfg) *tea.Program {
+	logChannel := make(chan log.Record, logRowsTotal)
+	syncer := NewSyncer(cfg, NewLogger(cfg, logChannel))
 
 	helpModel := help.New()
 
@@ -268,6 +459,8 @@ func NewProgram(cfg ProgramCfg) *tea.Program {
 		logCh:        logChannel,
 		logs:         []log.Record{},
 		showLog:      true,
+		dryRun:       cfg.DryRun,
+		patchPath:    cfg.PatchPath,
 	}) //, tea.WithAltScreen())
 }
 
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	syncer "github.com/flytaly/linksyncer/cmd/syncher"
+	linksyncer "github.com/flytaly/linksyncer/pkg/syncer"
+	"github.com/spf13/cobra"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [batch-id]",
+	Short: "Revert changes made by the last synchronization or by the given batch",
+	Long: `Revert changes made by the last synchronization or by the given batch.
+
+Rewritten files are restored from the journal and moved files are moved back to their previous locations.
+Nothing is reverted if any of the rewritten files have been edited since.
+With --dry-run the files that would be restored and moved back are only listed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := getConfig(cmd)
+		if cfg.JournalDir == "" {
+			fmt.Println("Error: journal is disabled")
+			os.Exit(1)
+		}
+		s := syncer.NewSyncer(cfg, syncer.NewLogger(cfg, nil))
+		defer s.Close()
+
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			printBatches(s)
+			return
+		}
+
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		batch, err := s.Undo(id)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		if cfg.DryRun {
+			printUndo(batch)
+			return
+		}
+		fmt.Printf("Batch %s reverted: %d files restored, %d files moved back\n", batch.ID, len(batch.Files), len(batch.Moves))
+	},
+}
+
+// printUndo prints the files that would be restored and moved back by undo
+func printUndo(batch *linksyncer.Batch) {
+	for _, f := range batch.Files {
+		fmt.Printf("restore %s\n", f.Path)
+	}
+	moves := []string{}
+	for from := range batch.Moves {
+		moves = append(moves, from)
+	}
+	sort.Strings(moves)
+	for _, from := range moves {
+		fmt.Printf("move %s -> %s\n", batch.Moves[from], from)
+	}
+	fmt.Fprintf(os.Stderr, "Dry run: batch %s would be reverted: %d files restored, %d files moved back\n", batch.ID, len(batch.Files), len(batch.Moves))
+}
+
+func printBatches(s *linksyncer.LinkSyncer) {
+	ids, err := s.BatchIDs()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	for _, id := range ids {
+		batch, err := s.Batch(id)
+		if err != nil {
+			fmt.Printf("%s\t%s\n", id, err)
+			continue
+		}
+		fmt.Printf("%s\t%s\t%d files, %d moves\n", batch.ID, batch.Time.Format("2006-01-02 15:04:05"), len(batch.Files), len(batch.Moves))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().Bool("list", false, "list batches in the journal")
+}
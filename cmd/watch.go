@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	syncer "github.com/flytaly/linksyncer/cmd/syncher"
@@ -12,9 +13,21 @@ import (
 var watchCmd = &cobra.Command{
 	Use:   "watch",
 	Short: "Watch for changes in the current directory and update links in Markdown files",
-	Long:  `Watch for changes in the current directory and update links in Markdown files`,
+	Long: `Watch for changes in the current directory and update links in Markdown files.
+
+Use "--no-tui" to run without the interface, e.g. as a systemd service or in a container.
+In this mode every event and rewrite is printed to stdout as a line of text or JSON ("--output json").
+The program stops on SIGINT or SIGTERM after finishing the current synchronization.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := getConfig(cmd)
+		if noTUI, _ := cmd.Flags().GetBool("no-tui"); noTUI {
+			output, _ := cmd.Flags().GetString("output")
+			if output != OutputText && output != OutputJSON {
+				fmt.Printf("Error: unknown output format %q, use %q or %q\n", output, OutputText, OutputJSON)
+				os.Exit(exitError)
+			}
+			os.Exit(runHeadless(cfg, &printer{w: os.Stdout, format: output}))
+		}
 		p := syncer.NewProgram(cfg)
 		if _, err := p.Run(); err != nil {
 			fmt.Printf("Error: %s", err)
@@ -34,4 +47,6 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	watchCmd.Flags().DurationP("interval", "i", 500*time.Millisecond, "poll interval duration (e.g. 1s, 500ms...)")
+	watchCmd.Flags().Bool("no-tui", false, "run without the interface and print events to stdout")
+	watchCmd.Flags().String("output", OutputText, `format of the events printed with --no-tui: "text" or "json"`)
 }
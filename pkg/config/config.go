@@ -0,0 +1,114 @@
+// Package config loads linksyncer settings from configuration files
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is a name of the configuration file in the root directory of the notes
+const ProjectFile = ".linksyncer.yaml"
+
+// Config contains settings that can be set in configuration files.
+// Zero values mean that the setting isn't set.
+type Config struct {
+	ExcludeDirs []string      `yaml:"exclude_dirs"` // names of the directories that aren't watched
+	Parsable    []string      `yaml:"parsable"`     // extensions of the notes
+	Linkable    []string      `yaml:"linkable"`     // extensions of the linked files
+	MaxSize     int64         `yaml:"max_size"`     // maximum size of the notes in KB
+	Interval    time.Duration `yaml:"interval"`     // polling interval in the watch mode
+	Log         string        `yaml:"log"`          // path to the log file
+	LinkStyle   string        `yaml:"link_style"`   // style of the rewritten links: keep, relative, root or absolute
+
+	// Files are paths of the loaded configuration files
+	Files []string `yaml:"-"`
+}
+
+// GlobalFile returns the path to the user's configuration file
+func GlobalFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "linksyncer", "config.yaml")
+}
+
+// Load reads the user's configuration file and then the project's file in the root directory,
+// so project settings take precedence. Missing files are ignored.
+func Load(root string) (*Config, error) {
+	cfg := &Config{}
+	for _, path := range []string{GlobalFile(), filepath.Join(root, ProjectFile)} {
+		if path == "" {
+			continue
+		}
+		if err := cfg.merge(path, false); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// LoadFile reads the configuration from the given file only
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	if err := cfg.merge(path, true); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// merge reads the file and overrides settings that are set in it
+func (c *Config) merge(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	file := Config{}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+	if file.MaxSize < 0 || file.Interval < 0 {
+		return fmt.Errorf("couldn't parse %s: max_size and interval shouldn't be negative", path)
+	}
+	// relative paths are resolved against the directory of the configuration file
+	if file.Log != "" && !filepath.IsAbs(file.Log) {
+		file.Log = filepath.Join(filepath.Dir(path), file.Log)
+	}
+
+	if file.ExcludeDirs != nil {
+		c.ExcludeDirs = file.ExcludeDirs
+	}
+	if file.Parsable != nil {
+		c.Parsable = file.Parsable
+	}
+	if file.Linkable != nil {
+		c.Linkable = file.Linkable
+	}
+	if file.MaxSize != 0 {
+		c.MaxSize = file.MaxSize
+	}
+	if file.Interval != 0 {
+		c.Interval = file.Interval
+	}
+	if file.Log != "" {
+		c.Log = file.Log
+	}
+	if file.LinkStyle != "" {
+		c.LinkStyle = file.LinkStyle
+	}
+	c.Files = append(c.Files, path)
+	return nil
+}
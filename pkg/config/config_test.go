@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("project settings override global ones", func(t *testing.T) {
+		xdg, root := t.TempDir(), t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+		writeConfig(t, filepath.Join(xdg, "linksyncer", "config.yaml"),
+			"exclude_dirs: [node_modules, build]\nmax_size: 512\nlog: linksyncer.log\n")
+		writeConfig(t, filepath.Join(root, ProjectFile),
+			"exclude_dirs: [attachments]\nparsable: [.md, .markdown]\ninterval: 2s\nlink_style: root\n")
+
+		cfg, err := Load(root)
+		assert.NoError(t, err)
+		assert.Equal(t, &Config{
+			ExcludeDirs: []string{"attachments"},
+			Parsable:    []string{".md", ".markdown"},
+			MaxSize:     512,
+			Interval:    2 * time.Second,
+			LinkStyle:   "root",
+			Log:         filepath.Join(xdg, "linksyncer", "linksyncer.log"),
+			Files:       []string{filepath.Join(xdg, "linksyncer", "config.yaml"), filepath.Join(root, ProjectFile)},
+		}, cfg)
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		cfg, err := Load(t.TempDir())
+		assert.NoError(t, err)
+		assert.Equal(t, &Config{}, cfg)
+
+		_, err = LoadFile(filepath.Join(t.TempDir(), "config.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		root := t.TempDir()
+		writeConfig(t, filepath.Join(root, ProjectFile), "max_size: [1]\n")
+		_, err := Load(root)
+		assert.ErrorContains(t, err, "couldn't parse")
+
+		writeConfig(t, filepath.Join(root, ProjectFile), "interval: -1s\n")
+		_, err = Load(root)
+		assert.ErrorContains(t, err, "shouldn't be negative")
+	})
+}
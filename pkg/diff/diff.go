@@ -0,0 +1,222 @@
+/*
+Package diff produces line-based unified diffs.
+*/
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// number of unchanged lines around changes
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	kind opKind
+	line string
+}
+
+// Unified returns unified diff of two texts, or empty string if they are equal.
+// oldName and newName are used in the header of the diff (e.g. "a/note.md" and "b/note.md").
+func Unified(oldName, newName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(edits) {
+		writeHunk(&sb, edits[h.from:h.to], h.oldStart, h.newStart)
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines keeping line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds the shortest edit script using Myers' algorithm
+func diffLines(a, b []string) []edit {
+	// common prefix and suffix don't need to be compared
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	return edits
+}
+
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack to collect the edits
+	edits := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{opEqual, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{opInsert, b[prevY]})
+			} else {
+				edits = append(edits, edit{opDelete, a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+type hunk struct {
+	from, to           int // range in the edit script
+	oldStart, newStart int // 0-based line numbers of the first line
+}
+
+// hunks groups changes with their context lines
+func hunks(edits []edit) []hunk {
+	result := []hunk{}
+	oldLine, newLine := 0, 0
+	var current *hunk
+	lastChange := -1
+
+	for i, e := range edits {
+		if e.kind != opEqual {
+			if current == nil || i-lastChange > 2*contextLines {
+				if current != nil {
+					current.to = lastChange + contextLines + 1
+					result = append(result, *current)
+				}
+				from := max(i-contextLines, 0)
+				if current != nil {
+					from = max(from, current.to)
+				}
+				current = &hunk{from: from, oldStart: oldLine - (i - from), newStart: newLine - (i - from)}
+			}
+			lastChange = i
+		}
+		switch e.kind {
+		case opEqual:
+			oldLine++
+			newLine++
+		case opDelete:
+			oldLine++
+		case opInsert:
+			newLine++
+		}
+	}
+	if current != nil {
+		current.to = min(lastChange+contextLines+1, len(edits))
+		result = append(result, *current)
+	}
+	return result
+}
+
+func writeHunk(sb *strings.Builder, edits []edit, oldStart, newStart int) {
+	oldCount, newCount := 0, 0
+	for _, e := range edits {
+		if e.kind != opInsert {
+			oldCount++
+		}
+		if e.kind != opDelete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+
+	for _, e := range edits {
+		switch e.kind {
+		case opEqual:
+			sb.WriteByte(' ')
+		case opDelete:
+			sb.WriteByte('-')
+		case opInsert:
+			sb.WriteByte('+')
+		}
+		sb.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
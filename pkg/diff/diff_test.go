@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	t.Run("equal", func(t *testing.T) {
+		assert.Equal(t, "", Unified("a/f", "b/f", []byte("text\n"), []byte("text\n")))
+	})
+
+	t.Run("single line", func(t *testing.T) {
+		want := "--- a/note.md\n+++ b/note.md\n@@ -1 +1 @@\n-![](img.png)\n\\ No newline at end of file\n+![](assets/img.png)\n\\ No newline at end of file\n"
+		assert.Equal(t, want, Unified("a/note.md", "b/note.md", []byte("![](img.png)"), []byte("![](assets/img.png)")))
+	})
+
+	t.Run("context and hunks", func(t *testing.T) {
+		lines := []string{}
+		for _, c := range "abcdefghijklmnop" {
+			lines = append(lines, string(c))
+		}
+		a := strings.Join(lines, "\n") + "\n"
+		lines[1] = "B"
+		lines[14] = "O"
+		b := strings.Join(lines, "\n") + "\n"
+
+		want := `--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -12,5 +12,5 @@
+ l
+ m
+ n
+-o
++O
+ p
+`
+		assert.Equal(t, want, Unified("a/f", "b/f", []byte(a), []byte(b)))
+	})
+
+	t.Run("insert and delete", func(t *testing.T) {
+		a := "1\n2\n3\n"
+		b := "0\n1\n3\n"
+		want := "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n+0\n 1\n-2\n 3\n"
+		assert.Equal(t, want, Unified("a/f", "b/f", []byte(a), []byte(b)))
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		want := "--- a/f\n+++ b/f\n@@ -0,0 +1 @@\n+text\n"
+		assert.Equal(t, want, Unified("a/f", "b/f", []byte(""), []byte("text\n")))
+	})
+}
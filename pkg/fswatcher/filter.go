@@ -0,0 +1,67 @@
+package fswatcher
+
+import "io/fs"
+
+// Decision is a result of a filter
+type Decision int
+
+const (
+	// Undecided passes the path to the next filter
+	Undecided Decision = iota
+	// Skip excludes the path from watching. Content of skipped directories isn't walked.
+	Skip
+	// Include watches the path without checking the rest of the filters
+	Include
+)
+
+// Filter decides whether the path, relative to the root, should be watched
+type Filter func(path string, d fs.DirEntry) Decision
+
+// FilterID identifies an added filter
+type FilterID int
+
+type filterEntry struct {
+	id     FilterID
+	filter Filter
+}
+
+// AddFilter adds the filter after the existing ones and returns its id.
+// Filters are evaluated in the order they were added, the first decision is used.
+// Paths that no filter decided on are watched.
+func (p *fsPoller) AddFilter(f Filter) FilterID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastFilter++
+	p.filters = append(p.filters, filterEntry{id: p.lastFilter, filter: f})
+	return p.lastFilter
+}
+
+// RemoveFilter removes the filter with the given id.
+// Paths that were skipped by it are added on the next scan.
+// The inotify watcher compares all files on the next scan to find them.
+func (p *fsPoller) RemoveFilter(id FilterID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, e := range p.filters {
+		if e.id == id {
+			p.filters = append(p.filters[:i:i], p.filters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Rescan does nothing, since the poller compares all files on every scan
+func (p *fsPoller) Rescan() {}
+
+// skip reports whether the path is excluded by filters. The caller must hold the lock.
+func (p *fsPoller) skip(path string, d fs.DirEntry) bool {
+	for _, e := range p.filters {
+		switch e.filter(path, d) {
+		case Skip:
+			return true
+		case Include:
+			return false
+		}
+	}
+	return false
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,7 +24,11 @@ type fsPoller struct {
 	done         chan struct{}
 	scanDone     chan struct{}
 	watchStopped chan struct{}
-	shouldSkip   func(fs.FileInfo) bool
+	filters      []filterEntry // filters of the watched paths in the order of evaluation
+	lastFilter   FilterID
+	renames      RenameDetection        // strategy used to pair removed and created files
+	hashes       map[string]fileHash    // content hashes of the files for RenameByHash detection
+	hashFilter   func(path string) bool // files hashed for RenameByHash detection, nil to hash all
 	fsys         fs.FS
 	// path to the root directory
 	root    string
@@ -32,10 +38,6 @@ type fsPoller struct {
 	closed bool
 }
 
-func (p *fsPoller) AddShouldSkipHook(fn func(fs.FileInfo) bool) {
-	p.shouldSkip = fn
-}
-
 // Add adds given name into the list of the watched paths.
 // If name is a directory, then retrieves FileInfo of nested files, saves them
 // and returns.
@@ -65,6 +67,7 @@ func (p *fsPoller) Add(name string) (map[string]*fs.FileInfo, error) {
 	for fname, fi := range list {
 		p.files[fname] = fi
 	}
+	p.updateHashes(nil)
 
 	p.watches[relativePath] = struct{}{}
 
@@ -91,16 +94,16 @@ func (p *fsPoller) listDirFiles(name string, recursively bool) (map[string]*fs.F
 			return err
 		}
 
-		stat, err := d.Info()
-		if err != nil {
-			return err
-		}
-		if p.shouldSkip != nil && p.shouldSkip(stat) { // skip
+		if p.skip(path, d) {
 			if d.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
 		files[path] = &stat
 		if d.IsDir() && path != name && !recursively {
 			return filepath.SkipDir
@@ -119,10 +122,15 @@ func (p *fsPoller) listDirFiles(name string, recursively bool) (map[string]*fs.F
 func (p *fsPoller) scanForChanges() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.compareFiles()
+}
 
-	addedFiles := map[string]*fs.FileInfo{}
+// compareFiles walks watched folders and compares files with the saved ones.
+// The caller must hold the lock.
+func (p *fsPoller) compareFiles() {
+	added := map[string]*fs.FileInfo{}
 	updated := map[string]*fs.FileInfo{}
-	renamed := map[string]*fs.FileInfo{}
+	removed := map[string]*fs.FileInfo{}
 
 	for path := range p.watches {
 		files, err := p.listDirFiles(path, true)
@@ -134,22 +142,19 @@ func (p *fsPoller) scanForChanges() {
 			p.errors <- err
 			continue
 		}
-		p.separate(files, addedFiles, updated)
+		p.separate(files, added, updated)
 	}
 
-	removed := []string{}
-
 	for path, oldInfo := range p.files {
 		newInfo, existed := updated[path]
 		if existed {
 			p.onFileWrite(path, oldInfo, newInfo)
 			continue
 		}
-		p.onFileRemove(path, addedFiles, oldInfo, renamed)
-		removed = append(removed, path)
+		removed[path] = oldInfo
 	}
 
-	for _, path := range removed {
+	for path := range removed {
 		delete(p.files, path)
 	}
 
@@ -157,43 +162,117 @@ func (p *fsPoller) scanForChanges() {
 		p.files[path] = fi
 	}
 
-	for name, info := range addedFiles {
+	for name, info := range added {
 		p.files[name] = info
-		if _, ok := renamed[name]; !ok {
-			err := p.SendEvent(Event{Op: Create, Name: name})
-			if err != nil {
-				p.errors <- err
-			}
-		}
+	}
+
+	p.onDirRename(removed, added)
+	p.onFileRename(removed, added)
+	p.updateHashes(removed)
+
+	for _, path := range sortedKeys(removed) {
+		p.notify(Event{Op: Remove, Name: path})
+	}
+	for _, path := range sortedKeys(added) {
+		p.notify(Event{Op: Create, Name: path})
 	}
 }
 
 // onFileWrite checks if file with given path was changed, if positive triggers Write event
 func (p *fsPoller) onFileWrite(path string, oldFi, newFi *fs.FileInfo) bool {
 	if (*oldFi).ModTime() != (*newFi).ModTime() {
-		err := p.SendEvent(Event{Op: Write, Name: path})
-		if err != nil {
-			p.errors <- err
-		}
+		p.notify(Event{Op: Write, Name: path})
 		return true
 	}
 	return false
 }
 
-// onFileRemove evaluates if path was removed or renamed and trigger corresponding event
-func (p *fsPoller) onFileRemove(path string, created map[string]*fs.FileInfo, oldFi *fs.FileInfo, renamed map[string]*fs.FileInfo) {
-	for newPath, newFi := range created {
-		if sameFile(*oldFi, *newFi) {
-			err := p.SendEvent(Event{Op: Rename, Name: path, NewPath: newPath})
-			if err != nil {
-				p.errors <- err
+// onDirRename finds renamed directories among removed and added paths and triggers
+// a single Rename event for each of them. Their content is considered moved along,
+// so nested paths that exist in the new directory are removed from both maps without events.
+func (p *fsPoller) onDirRename(removed, added map[string]*fs.FileInfo) {
+	created := []string{}
+	for _, path := range sortedKeys(added) {
+		if (*added[path]).IsDir() {
+			created = append(created, path)
+		}
+	}
+	if len(created) == 0 {
+		return
+	}
+
+	// parent directories go first, so their subdirectories are moved with them
+	for _, path := range sortedKeys(removed) {
+		oldFi, ok := removed[path]
+		if !ok || !(*oldFi).IsDir() {
+			continue
+		}
+		newPath := ""
+		for i, c := range created {
+			if newFi, ok := added[c]; ok && p.sameDir(path, *oldFi, c, *newFi, removed, added) {
+				newPath = c
+				created = append(created[:i], created[i+1:]...)
+				break
+			}
+		}
+		if newPath == "" {
+			continue
+		}
+		delete(removed, path)
+		delete(added, newPath)
+		p.notify(Event{Op: Rename, Name: path, NewPath: newPath, IsDir: true})
+
+		prefix := path + "/"
+		for nested := range removed {
+			if !strings.HasPrefix(nested, prefix) {
+				continue
+			}
+			moved := newPath + "/" + strings.TrimPrefix(nested, prefix)
+			if _, ok := added[moved]; ok {
+				delete(removed, nested)
+				delete(added, moved)
+				p.moveHash(nested, moved)
+			}
+		}
+	}
+}
+
+// onFileRename finds renamed files among removed and added paths and triggers Rename events.
+// Added files are grouped by size, so a removed file is compared only with files of the same size.
+func (p *fsPoller) onFileRename(removed, added map[string]*fs.FileInfo) {
+	created := map[int64][]string{}
+	for _, path := range sortedKeys(added) {
+		if fi := added[path]; !(*fi).IsDir() {
+			created[(*fi).Size()] = append(created[(*fi).Size()], path)
+		}
+	}
+	if len(created) == 0 {
+		return
+	}
+
+	for _, path := range sortedKeys(removed) {
+		oldFi := removed[path]
+		if (*oldFi).IsDir() {
+			continue
+		}
+		group := created[(*oldFi).Size()]
+		for i, newPath := range group {
+			if !p.same(path, *oldFi, newPath, *added[newPath]) {
+				continue
 			}
-			renamed[newPath] = newFi
-			return
+			created[(*oldFi).Size()] = append(group[:i], group[i+1:]...)
+			delete(removed, path)
+			delete(added, newPath)
+			p.moveHash(path, newPath)
+			p.notify(Event{Op: Rename, Name: path, NewPath: newPath})
+			break
 		}
 	}
-	err := p.SendEvent(Event{Op: Remove, Name: path})
-	if err != nil {
+}
+
+// notify sends the event and reports an error if the watcher is closed
+func (p *fsPoller) notify(e Event) {
+	if err := p.SendEvent(e); err != nil {
 		p.errors <- err
 	}
 }
@@ -233,6 +312,11 @@ func (p *fsPoller) WatchedList() map[string]*fs.FileInfo {
 }
 
 func (p *fsPoller) Start(interval time.Duration) error {
+	return p.run(interval, p.Scan)
+}
+
+// run calls scan on every tick until the watcher is stopped or closed
+func (p *fsPoller) run(interval time.Duration, scan func()) error {
 	if interval < MIN_INTERVAL {
 		interval = MIN_INTERVAL
 	}
@@ -252,7 +336,7 @@ func (p *fsPoller) Start(interval time.Duration) error {
 		case <-p.done:
 			return nil
 		case <-time.After(interval):
-			p.Scan()
+			scan()
 		}
 	}
 }
@@ -269,11 +353,16 @@ func (p *fsPoller) Stop() {
 
 // Scan scans watched directories for changes
 func (p *fsPoller) Scan() {
+	p.scanWith(p.scanForChanges)
+}
+
+// scanWith calls scan and notifies that the scan is complete
+func (p *fsPoller) scanWith(scan func()) {
 	if p.closed {
 		return
 	}
 
-	p.scanForChanges()
+	scan()
 	select {
 	case p.scanDone <- struct{}{}:
 	case <-p.done:
@@ -285,6 +374,7 @@ func (p *fsPoller) Remove(name string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	delete(p.files, name)
+	delete(p.hashes, name)
 	return nil
 }
 
@@ -315,3 +405,12 @@ func (p *fsPoller) Events() <-chan Event {
 func (p *fsPoller) ScanComplete() <-chan struct{} {
 	return p.scanDone
 }
+
+func sortedKeys(m map[string]*fs.FileInfo) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -8,6 +8,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"testing/fstest"
@@ -48,6 +49,25 @@ func createFS(files []string) fstest.MapFS {
 	return ff
 }
 
+// scanEvents runs a scan and collects sent events
+func scanEvents(t *testing.T, w FsWatcher) []Event {
+	t.Helper()
+	go w.Scan()
+	events := []Event{}
+	for {
+		select {
+		case e := <-w.Events():
+			events = append(events, e)
+		case err := <-w.Errors():
+			t.Error(err)
+		case <-w.ScanComplete():
+			return events
+		case <-time.After(time.Second * 2):
+			t.Fatal("scan wasn't completed")
+		}
+	}
+}
+
 func TestAdd(t *testing.T) {
 	t.Run("add files", func(t *testing.T) {
 		root := j("path", "notes")
@@ -290,8 +310,7 @@ func TestEvent(t *testing.T) {
 		failIfErr(t, err)
 
 		evs := map[string]Event{}
-		evs[dir1] = Event{Op: Rename, Name: dir1, NewPath: j(dir2, dir1)}
-		evs[moveFrom] = Event{Op: Rename, Name: moveFrom, NewPath: moveTo}
+		evs[dir1] = Event{Op: Rename, Name: dir1, NewPath: j(dir2, dir1), IsDir: true}
 		ExpectEvents(t, p, minWait, evs)
 
 		go func() {
@@ -309,6 +328,51 @@ func TestEvent(t *testing.T) {
 		assert.NotContainsf(t, p.files, dir1, "shouldn't contain previous path")
 		assert.NotContainsf(t, p.files, moveFrom, "shouldn't contain previous path")
 		assert.NotContainsf(t, p.watches, dir1, "shouldn't watch removed path")
+		assert.Containsf(t, p.files, moveTo, "should contain moved file")
+	})
+
+	t.Run("RENAME directory", func(t *testing.T) {
+		fsys := createFS([]string{
+			"notes", j("notes", "projects"), j("notes", "projects", "a.md"),
+			j("notes", "projects", "sub"), j("notes", "projects", "sub", "b.png"),
+			j("notes", "projects", "sub", "c.png"), j("notes", "d.md"),
+		})
+		p := makePoller(fsys, ".")
+		_, err := p.Add(".")
+		failIfErr(t, err)
+
+		from, to := j("notes", "projects"), j("notes", "archive")
+		evs := map[string]Event{}
+		evs[from] = Event{Op: Rename, Name: from, NewPath: to, IsDir: true}
+		// files that were changed inside the renamed directory still trigger events
+		evs[j(from, "sub", "c.png")] = Event{Op: Remove, Name: j(from, "sub", "c.png")}
+		evs[j(to, "e.md")] = Event{Op: Create, Name: j(to, "e.md")}
+		ExpectEvents(t, p, minWait, evs)
+
+		go func() {
+			failIfErr(t, p.Start(0))
+		}()
+
+		go func() {
+			time.Sleep(time.Millisecond * 2)
+			for path, file := range fsys {
+				if path == from || strings.HasPrefix(path, from+"/") {
+					if path != j(from, "sub", "c.png") {
+						fsys[to+strings.TrimPrefix(path, from)] = file
+					}
+					delete(fsys, path)
+				}
+			}
+			fsys[j(to, "e.md")] = &fstest.MapFile{Data: []byte("new")}
+		}()
+
+		<-p.done
+		for _, path := range []string{to, j(to, "a.md"), j(to, "sub", "b.png"), j(to, "e.md")} {
+			assert.Contains(t, p.files, path, "should contain moved path")
+		}
+		for _, path := range []string{from, j(from, "a.md"), j(from, "sub", "b.png"), j(from, "sub", "c.png")} {
+			assert.NotContains(t, p.files, path, "shouldn't contain previous path")
+		}
 	})
 
 	t.Run("WRITE", func(t *testing.T) {
@@ -355,8 +419,11 @@ func TestShouldSkipHook(t *testing.T) {
 			fsys[f] = &fstest.MapFile{}
 		}
 		p := makePoller(fsys, root)
-		p.AddShouldSkipHook(func(fi fs.FileInfo) bool {
-			return !fi.IsDir() && filepath.Ext(fi.Name()) != ".md"
+		p.AddFilter(func(_ string, d fs.DirEntry) Decision {
+			if !d.IsDir() && filepath.Ext(d.Name()) != ".md" {
+				return Skip
+			}
+			return Undecided
 		})
 
 		_, err := p.Add(root)
@@ -369,6 +436,69 @@ func TestShouldSkipHook(t *testing.T) {
 			assert.Contains(t, p.files, f)
 		}
 	})
+
+	t.Run("skip paths", func(t *testing.T) {
+		fsys := createFS([]string{"a/build/note.md", "b/build/note.md", "a/note.md"})
+		p := makePoller(fsys, ".")
+		p.AddFilter(func(path string, _ fs.DirEntry) Decision {
+			if path == "a/build" {
+				return Skip
+			}
+			return Undecided
+		})
+
+		_, err := p.Add(".")
+		failIfErr(t, err)
+
+		assert.NotContains(t, p.files, "a/build")
+		assert.NotContains(t, p.files, "a/build/note.md")
+		assert.Contains(t, p.files, "b/build/note.md")
+		assert.Contains(t, p.files, "a/note.md")
+	})
+}
+
+func TestFilters(t *testing.T) {
+	fsys := createFS([]string{"archive", "archive/2019", "archive/2019/a.md", "archive/2019/keep.md", "archive/2020/b.md", "c.md"})
+	skipPrefix := func(prefix string) Filter {
+		return func(path string, _ fs.DirEntry) Decision {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return Skip
+			}
+			return Undecided
+		}
+	}
+
+	t.Run("ordered evaluation", func(t *testing.T) {
+		p := newFsPoller(fsys, ".")
+		p.AddFilter(func(path string, _ fs.DirEntry) Decision {
+			if path == "archive/2019" || path == "archive/2019/keep.md" {
+				return Include
+			}
+			return Undecided
+		})
+		p.AddFilter(skipPrefix("archive/2019"))
+
+		list, err := p.Add(".")
+		failIfErr(t, err)
+		assert.Equal(t, []string{".", "archive", "archive/2019", "archive/2019/keep.md", "archive/2020", "archive/2020/b.md", "c.md"}, sortedKeys(list))
+	})
+
+	t.Run("remove filter", func(t *testing.T) {
+		p := newFsPoller(fsys, ".")
+		id := p.AddFilter(skipPrefix("archive/2019"))
+		p.AddFilter(skipPrefix("archive/2020"))
+		_, err := p.Add(".")
+		failIfErr(t, err)
+		assert.Equal(t, []string{".", "archive", "c.md"}, sortedKeys(p.files))
+
+		p.RemoveFilter(id)
+		events := scanEvents(t, p)
+		assert.Equal(t, []Event{
+			{Op: Create, Name: "archive/2019"},
+			{Op: Create, Name: "archive/2019/a.md"},
+			{Op: Create, Name: "archive/2019/keep.md"},
+		}, events)
+	})
 }
 
 func ExpectEvents(t *testing.T, p *fsPoller, await time.Duration, want map[string]Event) {
@@ -11,6 +11,7 @@ type Event struct {
 	Name    string // Path to the file or directory
 	NewPath string // new path after rename operation
 	Op      Op     // File operation that triggered the event.
+	IsDir   bool   // renamed path is a directory, its content was moved along without separate events
 }
 
 // Op describes a type of event
@@ -46,19 +47,27 @@ type FsWatcher interface {
 	Events() <-chan Event
 	Errors() <-chan error
 	Add(name string) (map[string]*fs.FileInfo, error)
+	WatchedList() map[string]*fs.FileInfo
 	Remove(name string) error
 	Close() error
 	Start(interval time.Duration) error
 	Stop()
-	AddShouldSkipHook(func(fi fs.FileInfo) bool)
+	AddFilter(f Filter) FilterID
+	RemoveFilter(id FilterID)
+	// Rescan makes the next scan compare all files, e.g. when a filter's decisions have changed
+	Rescan()
 	SendEvent(ev Event) error
 	ScanComplete() <-chan struct{}
 	Scan()
 }
 
 // New creates a new Watcher.
-func NewFsPoller(fsys fs.FS, root string) FsWatcher {
-	return &fsPoller{
+func NewFsPoller(fsys fs.FS, root string, options ...Option) FsWatcher {
+	return newFsPoller(fsys, root, options...)
+}
+
+func newFsPoller(fsys fs.FS, root string, options ...Option) *fsPoller {
+	p := &fsPoller{
 		events:       make(chan Event),
 		errors:       make(chan error),
 		closed:       false,
@@ -70,5 +79,10 @@ func NewFsPoller(fsys fs.FS, root string) FsWatcher {
 		watches:      map[string]struct{}{},
 		mu:           new(sync.Mutex),
 		files:        make(map[string]*fs.FileInfo),
+		hashes:       map[string]fileHash{},
+	}
+	for _, option := range options {
+		option(p)
 	}
+	return p
 }
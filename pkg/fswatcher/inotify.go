@@ -0,0 +1,371 @@
+//go:build linux
+
+package fswatcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyWatcher is an implementation of FsWatcher interface based on Linux inotify.
+// Instead of walking watched folders it reads queued kernel events on every scan,
+// so scans are cheap regardless of the number of files.
+// The list of files and the skip hook are shared with fsPoller.
+type inotifyWatcher struct {
+	*fsPoller
+	fd   int
+	wds  map[int]string // watch descriptors -> watched directories
+	dirs map[string]int // watched directories -> watch descriptors
+	// rescan makes the next scan compare all files. It's set without the lock,
+	// because it can be requested while events of the current scan are being handled.
+	rescan atomic.Bool
+}
+
+// change is a kernel event with a path relative to the root
+type change struct {
+	name    string
+	newPath string // destination of the paired move
+	moved   bool   // IN_MOVED_FROM event
+}
+
+// NewInotify creates a new inotify based Watcher.
+func NewInotify(fsys fs.FS, root string, options ...Option) (FsWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize inotify: %w", err)
+	}
+	return &inotifyWatcher{
+		fsPoller: newFsPoller(fsys, root, options...),
+		fd:       fd,
+		wds:      map[int]string{},
+		dirs:     map[string]int{},
+	}, nil
+}
+
+// Add adds given name into the list of the watched paths and starts watching
+// the directory and its subdirectories.
+func (w *inotifyWatcher) Add(name string) (map[string]*fs.FileInfo, error) {
+	list, err := w.fsPoller.Add(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, fi := range list {
+		if (*fi).IsDir() {
+			if err = w.addWatch(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func (w *inotifyWatcher) addWatch(dir string) error {
+	if _, ok := w.dirs[dir]; ok {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, filepath.Join(w.root, filepath.FromSlash(dir)), inotifyMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return fmt.Errorf("couldn't watch %s: inotify watch limit is reached, increase fs.inotify.max_user_watches", dir)
+		}
+		return fmt.Errorf("couldn't watch %s: %w", dir, err)
+	}
+	w.wds[wd] = dir
+	w.dirs[dir] = wd
+	return nil
+}
+
+// removeWatches stops watching the directory and its subdirectories
+func (w *inotifyWatcher) removeWatches(dir string) {
+	for d, wd := range w.dirs {
+		if d == dir || strings.HasPrefix(d, dir+"/") {
+			_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, d)
+			delete(w.wds, wd)
+		}
+	}
+}
+
+// RemoveFilter removes the filter and compares all files on the next scan,
+// because directories skipped by the filter aren't watched and their changes aren't received
+func (w *inotifyWatcher) RemoveFilter(id FilterID) {
+	w.fsPoller.RemoveFilter(id)
+	w.Rescan()
+}
+
+// Rescan makes the next scan compare all files instead of reading kernel events only
+func (w *inotifyWatcher) Rescan() {
+	w.rescan.Store(true)
+}
+
+func (w *inotifyWatcher) Start(interval time.Duration) error {
+	return w.run(interval, w.Scan)
+}
+
+// Scan processes events queued since the previous scan
+func (w *inotifyWatcher) Scan() {
+	w.scanWith(w.readEvents)
+}
+
+func (w *inotifyWatcher) readEvents() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fd < 0 {
+		return
+	}
+
+	changes, overflow, err := w.drain()
+	if err != nil {
+		w.errors <- err
+	}
+	// some events were lost or filters have changed, so compare all files
+	if overflow || w.rescan.Swap(false) {
+		w.compareFiles()
+		w.syncWatches()
+		return
+	}
+
+	for i, c := range changes {
+		if i > 0 && !c.moved && !changes[i-1].moved && c.name == changes[i-1].name {
+			continue
+		}
+		if c.moved && c.newPath != "" {
+			w.rename(c.name, c.newPath)
+			continue
+		}
+		w.reconcile(c.name)
+	}
+	w.updateHashes(nil)
+}
+
+// drain reads all queued events and pairs IN_MOVED_FROM and IN_MOVED_TO by their cookies
+func (w *inotifyWatcher) drain() (changes []change, overflow bool, err error) {
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+	moves := map[uint32]int{} // cookie -> index of the IN_MOVED_FROM change
+
+	for {
+		n, err := syscall.Read(w.fd, buf[:])
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if errors.Is(err, syscall.EAGAIN) { // no more queued events
+			return changes, overflow, nil
+		}
+		if err != nil {
+			return changes, overflow, fmt.Errorf("couldn't read inotify events: %w", err)
+		}
+		if n == 0 {
+			return changes, overflow, nil
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(raw.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				overflow = true
+				continue
+			}
+			if raw.Mask&syscall.IN_IGNORED != 0 { // watch was removed
+				if dir, ok := w.wds[int(raw.Wd)]; ok && w.dirs[dir] == int(raw.Wd) {
+					delete(w.dirs, dir)
+				}
+				delete(w.wds, int(raw.Wd))
+				continue
+			}
+			dir, ok := w.wds[int(raw.Wd)]
+			if !ok || name == "" {
+				continue
+			}
+
+			p := path.Join(dir, name)
+			switch {
+			case raw.Mask&syscall.IN_MOVED_FROM != 0:
+				moves[raw.Cookie] = len(changes)
+				changes = append(changes, change{name: p, moved: true})
+			case raw.Mask&syscall.IN_MOVED_TO != 0:
+				if i, ok := moves[raw.Cookie]; ok {
+					changes[i].newPath = p
+					delete(moves, raw.Cookie)
+					continue
+				}
+				changes = append(changes, change{name: p}) // moved from outside
+			default:
+				changes = append(changes, change{name: p})
+			}
+		}
+	}
+}
+
+// reconcile compares the file with the saved one and sends corresponding events
+func (w *inotifyWatcher) reconcile(name string) {
+	info, err := fs.Stat(w.fsys, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		w.errors <- err
+		return
+	}
+	oldInfo, known := w.files[name]
+	skip := err != nil || w.skip(name, fs.FileInfoToDirEntry(info))
+
+	switch {
+	case skip:
+		if known {
+			w.remove(name)
+		}
+	case !known:
+		w.create(name)
+	case (*oldInfo).IsDir() != info.IsDir():
+		w.remove(name)
+		w.create(name)
+	default:
+		w.files[name] = &info
+		if !info.IsDir() && (*oldInfo).ModTime() != info.ModTime() {
+			w.send(Event{Op: Write, Name: name})
+		}
+	}
+}
+
+// create saves the file, or the directory with its content, and sends Create events
+func (w *inotifyWatcher) create(name string) {
+	list, err := w.listDirFiles(name, true)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.errors <- err
+		}
+		return
+	}
+	for _, p := range sortedKeys(list) {
+		if _, ok := w.files[p]; ok {
+			continue
+		}
+		w.files[p] = list[p]
+		if (*list[p]).IsDir() {
+			if err = w.addWatch(p); err != nil {
+				w.errors <- err
+			}
+		}
+		w.send(Event{Op: Create, Name: p})
+	}
+}
+
+// remove deletes the file, or the directory with its content, and sends Remove events
+func (w *inotifyWatcher) remove(name string) {
+	if fi, ok := w.files[name]; ok && (*fi).IsDir() {
+		w.removeWatches(name)
+	}
+	for _, p := range w.nested(name) {
+		delete(w.files, p)
+		delete(w.hashes, p)
+		w.send(Event{Op: Remove, Name: p})
+	}
+	delete(w.files, name)
+	delete(w.hashes, name)
+	w.send(Event{Op: Remove, Name: name})
+}
+
+// rename moves the file, or the directory with its content, and sends a Rename event
+func (w *inotifyWatcher) rename(from, to string) {
+	fi, known := w.files[from]
+	info, err := fs.Stat(w.fsys, to)
+	if !known || err != nil || w.skip(to, fs.FileInfoToDirEntry(info)) {
+		w.reconcile(from)
+		w.reconcile(to)
+		return
+	}
+	if _, ok := w.files[to]; ok { // destination was replaced
+		w.remove(to)
+	}
+
+	delete(w.files, from)
+	w.files[to] = &info
+	w.moveHash(from, to)
+	w.send(Event{Op: Rename, Name: from, NewPath: to, IsDir: info.IsDir()})
+	if !(*fi).IsDir() {
+		return
+	}
+
+	// the content is moved along with the directory, so events aren't sent for nested files
+	for _, p := range w.nested(from) {
+		newPath := to + strings.TrimPrefix(p, from)
+		w.files[newPath] = w.files[p]
+		delete(w.files, p)
+		w.moveHash(p, newPath)
+	}
+	for d, wd := range w.dirs {
+		if d == from || strings.HasPrefix(d, from+"/") {
+			newDir := to + strings.TrimPrefix(d, from)
+			delete(w.dirs, d)
+			w.dirs[newDir] = wd
+			w.wds[wd] = newDir
+		}
+	}
+}
+
+// nested returns sorted paths of the saved files inside the directory
+func (w *inotifyWatcher) nested(dir string) []string {
+	result := []string{}
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	for p := range w.files {
+		if p != dir && strings.HasPrefix(p, prefix) {
+			result = append(result, p)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// syncWatches watches new directories and stops watching removed ones
+func (w *inotifyWatcher) syncWatches() {
+	for d := range w.dirs {
+		if fi, ok := w.files[d]; !ok || !(*fi).IsDir() {
+			w.removeWatches(d)
+		}
+	}
+	for p, fi := range w.files {
+		if (*fi).IsDir() {
+			if err := w.addWatch(p); err != nil {
+				w.errors <- err
+			}
+		}
+	}
+}
+
+// send sends the event unless the watcher is closed
+func (w *inotifyWatcher) send(e Event) {
+	_ = w.SendEvent(e)
+}
+
+func (w *inotifyWatcher) Close() error {
+	err := w.fsPoller.Close()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fd >= 0 {
+		if closeErr := syscall.Close(w.fd); err == nil {
+			err = closeErr
+		}
+		w.fd = -1
+	}
+	return err
+}
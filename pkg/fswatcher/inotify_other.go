@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fswatcher
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// NewInotify returns an error since inotify is only available on Linux.
+func NewInotify(fsys fs.FS, root string, options ...Option) (FsWatcher, error) {
+	return nil, errors.New("inotify backend is only supported on Linux")
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package fswatcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeInotify(t *testing.T, files map[string]string) (*inotifyWatcher, string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		failIfErr(t, os.MkdirAll(filepath.Dir(p), 0755))
+		failIfErr(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	w, err := NewInotify(os.DirFS(root), root)
+	failIfErr(t, err)
+	t.Cleanup(func() { w.Close() })
+	w.AddFilter(func(path string, d fs.DirEntry) Decision {
+		if d.IsDir() && strings.HasPrefix(path, ".") && path != "." {
+			return Skip
+		}
+		return Undecided
+	})
+	_, err = w.Add(root)
+	failIfErr(t, err)
+	return w.(*inotifyWatcher), root
+}
+
+func TestInotify(t *testing.T) {
+	t.Run("rename", func(t *testing.T) {
+		w, root := makeInotify(t, map[string]string{"a.md": "a", "dir/b.png": "b"})
+		failIfErr(t, os.Rename(filepath.Join(root, "a.md"), filepath.Join(root, "dir/a.md")))
+		failIfErr(t, os.Rename(filepath.Join(root, "dir/b.png"), filepath.Join(root, "b.png")))
+
+		assert.Equal(t, []Event{
+			{Op: Rename, Name: "a.md", NewPath: "dir/a.md"},
+			{Op: Rename, Name: "dir/b.png", NewPath: "b.png"},
+		}, scanEvents(t, w))
+		assert.Contains(t, w.WatchedList(), "dir/a.md")
+		assert.NotContains(t, w.WatchedList(), "a.md")
+	})
+
+	t.Run("rename directory", func(t *testing.T) {
+		w, root := makeInotify(t, map[string]string{"dir/a.md": "a", "dir/sub/b.png": "b"})
+		failIfErr(t, os.Rename(filepath.Join(root, "dir"), filepath.Join(root, "new")))
+
+		assert.Equal(t, []Event{{Op: Rename, Name: "dir", NewPath: "new", IsDir: true}}, scanEvents(t, w))
+		assert.Contains(t, w.WatchedList(), "new/sub/b.png")
+		assert.NotContains(t, w.WatchedList(), "dir/sub/b.png")
+
+		// events from the renamed directory have new paths
+		failIfErr(t, os.WriteFile(filepath.Join(root, "new/sub/c.md"), []byte("c"), 0644))
+		assert.Equal(t, []Event{{Op: Create, Name: "new/sub/c.md"}}, scanEvents(t, w))
+	})
+
+	t.Run("create, write and remove", func(t *testing.T) {
+		w, root := makeInotify(t, map[string]string{"a.md": "a", "b.md": "b"})
+		failIfErr(t, os.MkdirAll(filepath.Join(root, "dir/sub"), 0755))
+		failIfErr(t, os.WriteFile(filepath.Join(root, "dir/sub/c.md"), []byte("c"), 0644))
+		later := time.Now().Add(time.Minute)
+		failIfErr(t, os.Chtimes(filepath.Join(root, "a.md"), later, later))
+		failIfErr(t, os.Remove(filepath.Join(root, "b.md")))
+
+		assert.ElementsMatch(t, []Event{
+			{Op: Create, Name: "dir"},
+			{Op: Create, Name: "dir/sub"},
+			{Op: Create, Name: "dir/sub/c.md"},
+			{Op: Write, Name: "a.md"},
+			{Op: Remove, Name: "b.md"},
+		}, scanEvents(t, w))
+
+		failIfErr(t, os.RemoveAll(filepath.Join(root, "dir")))
+		assert.ElementsMatch(t, []Event{
+			{Op: Remove, Name: "dir/sub/c.md"},
+			{Op: Remove, Name: "dir/sub"},
+			{Op: Remove, Name: "dir"},
+		}, scanEvents(t, w))
+		assert.Empty(t, w.dirs["dir"])
+	})
+
+	t.Run("move in and out of the watched directory", func(t *testing.T) {
+		w, root := makeInotify(t, map[string]string{"a.md": "a", ".hidden/b.md": "b"})
+		failIfErr(t, os.Rename(filepath.Join(root, "a.md"), filepath.Join(root, ".hidden/a.md")))
+		failIfErr(t, os.Rename(filepath.Join(root, ".hidden/b.md"), filepath.Join(root, "b.md")))
+
+		assert.Equal(t, []Event{
+			{Op: Remove, Name: "a.md"},
+			{Op: Create, Name: "b.md"},
+		}, scanEvents(t, w))
+	})
+
+	t.Run("remove filter and rescan", func(t *testing.T) {
+		w, root := makeInotify(t, map[string]string{"a.md": "a", "archive/b.md": "b"})
+		id := w.AddFilter(func(path string, _ fs.DirEntry) Decision {
+			if strings.HasPrefix(path, "archive") {
+				return Skip
+			}
+			return Undecided
+		})
+		// the filter is added after the directory was watched, so the next full scan removes it
+		w.Rescan()
+		assert.ElementsMatch(t, []Event{
+			{Op: Remove, Name: "archive"},
+			{Op: Remove, Name: "archive/b.md"},
+		}, scanEvents(t, w))
+
+		w.RemoveFilter(id)
+		assert.ElementsMatch(t, []Event{
+			{Op: Create, Name: "archive"},
+			{Op: Create, Name: "archive/b.md"},
+		}, scanEvents(t, w))
+
+		// changes in the directory are received again
+		failIfErr(t, os.WriteFile(filepath.Join(root, "archive/c.md"), []byte("c"), 0644))
+		assert.Equal(t, []Event{{Op: Create, Name: "archive/c.md"}}, scanEvents(t, w))
+	})
+
+	t.Run("read errors", func(t *testing.T) {
+		w, _ := makeInotify(t, map[string]string{"a.md": "a"})
+		failIfErr(t, syscall.Close(w.fd))
+		_, _, err := w.drain()
+		w.fd = -1
+		assert.ErrorIs(t, err, syscall.EBADF)
+	})
+
+	t.Run("no events without changes", func(t *testing.T) {
+		w, _ := makeInotify(t, map[string]string{"a.md": "a"})
+		assert.Empty(t, scanEvents(t, w))
+	})
+}
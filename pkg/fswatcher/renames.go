@@ -0,0 +1,156 @@
+package fswatcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// RenameDetection is a strategy used to pair removed and created files as renamed
+type RenameDetection int
+
+const (
+	// RenameByInode pairs paths that point to the same file (the same inode on Unix)
+	RenameByInode RenameDetection = iota
+	// RenameByHash pairs files with the same content. It works on file systems that don't
+	// preserve inodes, such as network mounts and FUSE, but files have to be read to be hashed.
+	RenameByHash
+)
+
+// Option configures a watcher
+type Option func(*fsPoller)
+
+// WithRenameDetection sets the strategy used to detect renamed files
+func WithRenameDetection(d RenameDetection) Option {
+	return func(p *fsPoller) {
+		p.renames = d
+	}
+}
+
+// WithHashFilter limits RenameByHash detection to the files reported by f, other files
+// aren't read and their renames are reported as removed and created files.
+// Files are hashed by the scan after f starts reporting them, so f can change its
+// decisions. f is called while the watcher is locked and mustn't call the watcher.
+func WithHashFilter(f func(path string) bool) Option {
+	return func(p *fsPoller) {
+		p.hashFilter = f
+	}
+}
+
+// fileHash is a content hash of the file with the stamp of the file when it was computed
+type fileHash struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+// hashable reports whether the file can be paired by its content.
+// Empty files are never paired, because all of them have the same content.
+func hashable(fi fs.FileInfo) bool {
+	return !fi.IsDir() && fi.Size() > 0
+}
+
+// fresh reports whether the hash was computed for the current content of the file
+func (h fileHash) fresh(fi fs.FileInfo) bool {
+	return h.size == fi.Size() && h.modTime.Equal(fi.ModTime())
+}
+
+// hash returns the content hash of the file, it's computed only if the file
+// has been changed since the cached hash was computed
+func (p *fsPoller) hash(path string, fi fs.FileInfo) (string, bool) {
+	if h, ok := p.hashes[path]; ok && h.fresh(fi) {
+		return h.sum, true
+	}
+	f, err := p.fsys.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", false
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	p.hashes[path] = fileHash{modTime: fi.ModTime(), size: fi.Size(), sum: sum}
+	return sum, true
+}
+
+// updateHashes forgets hashes of the removed files and computes hashes of the files
+// that are new, changed, or have just passed the hash filter, so they can be paired
+// when they are renamed. Only the files without a fresh hash are read.
+func (p *fsPoller) updateHashes(removed map[string]*fs.FileInfo) {
+	if p.renames != RenameByHash {
+		return
+	}
+	for path := range removed {
+		delete(p.hashes, path)
+	}
+	for path, fi := range p.files {
+		if !hashable(*fi) {
+			continue
+		}
+		if h, ok := p.hashes[path]; ok && h.fresh(*fi) {
+			continue
+		}
+		if p.hashFilter == nil || p.hashFilter(path) {
+			p.hash(path, *fi)
+		}
+	}
+}
+
+// moveHash moves the cached hash of the renamed file
+func (p *fsPoller) moveHash(from, to string) {
+	if h, ok := p.hashes[from]; ok {
+		delete(p.hashes, from)
+		p.hashes[to] = h
+	}
+}
+
+// same reports whether the removed file and the created file are the same file
+func (p *fsPoller) same(oldPath string, oldFi fs.FileInfo, newPath string, newFi fs.FileInfo) bool {
+	if p.renames != RenameByHash {
+		return sameFile(oldFi, newFi)
+	}
+	if !hashable(oldFi) || oldFi.Size() != newFi.Size() {
+		return false
+	}
+	// the removed file can't be read, so only the hash computed before it was removed can be used
+	old, ok := p.hashes[oldPath]
+	if !ok || !old.fresh(oldFi) {
+		return false
+	}
+	sum, ok := p.hash(newPath, newFi)
+	return ok && old.sum == sum
+}
+
+// sameDir reports whether the removed directory was renamed to the created one.
+// With hash detection directories are compared by their content: every removed file
+// inside the old directory should have the same content at the same place in the new one.
+func (p *fsPoller) sameDir(oldPath string, oldFi fs.FileInfo, newPath string, newFi fs.FileInfo, removed, added map[string]*fs.FileInfo) bool {
+	if p.renames != RenameByHash {
+		return sameFile(oldFi, newFi)
+	}
+	matched := 0
+	prefix := oldPath + "/"
+	for path, fi := range removed {
+		if !strings.HasPrefix(path, prefix) || (*fi).IsDir() {
+			continue
+		}
+		movedPath := newPath + "/" + strings.TrimPrefix(path, prefix)
+		moved, ok := added[movedPath]
+		if !ok {
+			return false
+		}
+		if !hashable(*fi) { // empty files are compared only by their paths
+			continue
+		}
+		if !p.same(path, *fi, movedPath, *moved) {
+			return false
+		}
+		matched++
+	}
+	return matched > 0
+}
@@ -0,0 +1,129 @@
+package fswatcher
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameDetection(t *testing.T) {
+	t1 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	makeFS := func() fstest.MapFS {
+		return fstest.MapFS{
+			"empty1.txt":        {ModTime: t1},
+			"a.md":              {Data: []byte("aaa"), ModTime: t1},
+			"b.md":              {Data: []byte("bbb"), ModTime: t1},
+			"dir/c.md":          {Data: []byte("ccc"), ModTime: t1},
+			"dir/sub/empty.txt": {ModTime: t1},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		strategy RenameDetection
+		change   func(fsys fstest.MapFS)
+		want     []Event
+	}{
+		{
+			name:     "empty files aren't paired by hash",
+			strategy: RenameByHash,
+			change: func(fsys fstest.MapFS) {
+				delete(fsys, "empty1.txt")
+				fsys["empty2.txt"] = &fstest.MapFile{ModTime: t1}
+			},
+			want: []Event{{Op: Remove, Name: "empty1.txt"}, {Op: Create, Name: "empty2.txt"}},
+		},
+		{
+			name:     "files with the same stamp are paired by inode",
+			strategy: RenameByInode,
+			change: func(fsys fstest.MapFS) {
+				delete(fsys, "empty1.txt")
+				fsys["empty2.txt"] = &fstest.MapFile{ModTime: t1}
+			},
+			want: []Event{{Op: Rename, Name: "empty1.txt", NewPath: "empty2.txt"}},
+		},
+		{
+			name:     "renamed file without preserved stamp",
+			strategy: RenameByHash,
+			change: func(fsys fstest.MapFS) {
+				delete(fsys, "a.md")
+				fsys["new/a.md"] = &fstest.MapFile{Data: []byte("aaa"), ModTime: t2}
+			},
+			want: []Event{{Op: Rename, Name: "a.md", NewPath: "new/a.md"}, {Op: Create, Name: "new"}},
+		},
+		{
+			name:     "files of the same size are paired by content",
+			strategy: RenameByHash,
+			change: func(fsys fstest.MapFS) {
+				fsys["b2.md"], fsys["a2.md"] = fsys["a.md"], fsys["b.md"]
+				delete(fsys, "a.md")
+				delete(fsys, "b.md")
+			},
+			want: []Event{
+				{Op: Rename, Name: "a.md", NewPath: "b2.md"},
+				{Op: Rename, Name: "b.md", NewPath: "a2.md"},
+			},
+		},
+		{
+			name:     "changed content isn't a rename",
+			strategy: RenameByHash,
+			change: func(fsys fstest.MapFS) {
+				delete(fsys, "a.md")
+				fsys["a2.md"] = &fstest.MapFile{Data: []byte("abc"), ModTime: t1}
+			},
+			want: []Event{{Op: Remove, Name: "a.md"}, {Op: Create, Name: "a2.md"}},
+		},
+		{
+			name:     "directory is paired by its content",
+			strategy: RenameByHash,
+			change: func(fsys fstest.MapFS) {
+				fsys["other/c.md"] = &fstest.MapFile{Data: []byte("ccc"), ModTime: t2}
+				fsys["other/sub/empty.txt"] = &fstest.MapFile{ModTime: t2}
+				delete(fsys, "dir/c.md")
+				delete(fsys, "dir/sub/empty.txt")
+			},
+			want: []Event{{Op: Rename, Name: "dir", NewPath: "other", IsDir: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := makeFS()
+			p := newFsPoller(fsys, ".", WithRenameDetection(tt.strategy))
+			_, err := p.Add(".")
+			failIfErr(t, err)
+
+			tt.change(fsys)
+			assert.Equal(t, tt.want, scanEvents(t, p))
+		})
+	}
+}
+
+func TestHashFilter(t *testing.T) {
+	t1 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("aaa"), ModTime: t1},
+		"b.md": {Data: []byte("bbb"), ModTime: t1},
+	}
+	tracked := map[string]bool{"a.md": true}
+	p := newFsPoller(fsys, ".", WithRenameDetection(RenameByHash), WithHashFilter(func(path string) bool {
+		return tracked[path]
+	}))
+	_, err := p.Add(".")
+	failIfErr(t, err)
+	assert.Contains(t, p.hashes, "a.md")
+	assert.NotContains(t, p.hashes, "b.md", "untracked files shouldn't be read")
+
+	tracked["b.md"] = true
+	assert.Empty(t, scanEvents(t, p))
+	assert.Contains(t, p.hashes, "b.md", "file should be hashed after it's tracked")
+
+	fsys["new/b.md"] = &fstest.MapFile{Data: []byte("bbb"), ModTime: t2}
+	delete(fsys, "b.md")
+	assert.Equal(t, []Event{{Op: Rename, Name: "b.md", NewPath: "new/b.md"}, {Op: Create, Name: "new"}}, scanEvents(t, p))
+}
@@ -0,0 +1,204 @@
+// Package ignore matches paths against gitignore-style ignore files
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// rule is a single pattern from an ignore file
+type rule struct {
+	re      *regexp.Regexp // matches paths relative to the directory of the ignore file
+	negate  bool           // pattern starts with "!", matched paths are included back
+	dirOnly bool           // pattern ends with "/", only directories are matched
+}
+
+// Matcher checks paths against rules from ignore files found in the root directory
+// and in nested directories. Rules of the nested files take precedence.
+// Ignore files are read once, when a path inside their directory is checked for the first time.
+type Matcher struct {
+	fsys  fs.FS
+	files []string          // names of the ignore files, later files take precedence
+	rules map[string][]rule // directory -> rules from its ignore files
+	mu    sync.Mutex
+}
+
+// New creates Matcher that reads ignore files with the given names
+func New(fsys fs.FS, files ...string) *Matcher {
+	return &Matcher{fsys: fsys, files: files, rules: map[string][]rule{}}
+}
+
+// IsIgnoreFile reports whether the path is one of the ignore files
+func (m *Matcher) IsIgnoreFile(name string) bool {
+	base := path.Base(name)
+	for _, f := range m.files {
+		if base == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets rules read from the ignore files in the directory,
+// so they are read again on the next check
+func (m *Matcher) Reset(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rules, dir)
+}
+
+// Ignored reports whether the path, relative to the root, is ignored.
+// Paths inside ignored directories are ignored too.
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+	name = path.Clean(name)
+	if name == "." || len(m.files) == 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.match(name, isDir)
+}
+
+// match checks the path against rules of all ignore files in its parent directories.
+// The last matched rule decides if the path is ignored.
+func (m *Matcher) match(name string, isDir bool) bool {
+	ignored := false
+	dir := "."
+	rest := name
+	for {
+		for _, r := range m.load(dir) {
+			if (!r.dirOnly || isDir) && r.re.MatchString(rest) {
+				ignored = !r.negate
+			}
+		}
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			return ignored
+		}
+		dir = path.Join(dir, rest[:i])
+		rest = rest[i+1:]
+	}
+}
+
+// load returns rules from ignore files in the directory
+func (m *Matcher) load(dir string) []rule {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+	rules := []rule{}
+	for _, f := range m.files {
+		data, err := fs.ReadFile(m.fsys, path.Join(dir, f))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, parse(data)...)
+	}
+	m.rules[dir] = rules
+	return rules
+}
+
+// parse returns rules from the content of an ignore file
+func parse(data []byte) []rule {
+	rules := []rule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if r, ok := parseLine(scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseLine(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\!") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// patterns with a slash at the beginning or in the middle are relative to the ignore file,
+	// other patterns match at any level below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp converts a gitignore glob into a regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"): // other consecutive asterisks are regular asterisks
+			sb.WriteString("[^/]*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, "\\", "\\\\") + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
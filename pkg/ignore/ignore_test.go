@@ -0,0 +1,80 @@
+package ignore
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnored(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore": {Data: []byte(
+			"# build outputs\n" +
+				"build/\n" +
+				"*.log\n" +
+				"!keep.log\n" +
+				"/generated.md\n" +
+				"docs/**/vendor\n" +
+				"temp?.md  \n" +
+				"\\#notes.md\n" +
+				"draft[0-9].md\n")},
+		".linksyncerignore":    {Data: []byte("private/\n")},
+		"notes/.gitignore":     {Data: []byte("/local.md\n!/build/\nsecret*\n")},
+		"notes/sub/.gitignore": {Data: []byte("!secret.md\n")},
+		"build/.gitignore":     {Data: []byte("!sub/\n")},
+	}
+	m := New(fsys, ".gitignore", ".linksyncerignore")
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"build", true, true},
+		{"build", false, false}, // only directories are matched by "build/"
+		{"a/b/build", true, true},
+		{"build/note.md", false, true},
+		{"app.log", false, true},
+		{"a/app.log", false, true},
+		{"a/keep.log", false, false},
+		{"generated.md", false, true},
+		{"a/generated.md", false, false},
+		{"docs/vendor", true, true},
+		{"docs/a/b/vendor/x.md", false, true},
+		{"vendor/x.md", false, false},
+		{"temp1.md", false, true},
+		{"temp10.md", false, false},
+		{"#notes.md", false, true},
+		{"draft1.md", false, true},
+		{"drafta.md", false, false},
+		{"private/note.md", false, true},
+		{"notes/local.md", false, true},
+		{"local.md", false, false},
+		{"notes/sub/local.md", false, false},
+		{"notes/build", true, false}, // rules of nested files take precedence
+		{"notes/build/x.md", false, false},
+		{"build/sub", true, true}, // paths inside ignored directories can't be included back
+		{"notes/secret.md", false, true},
+		{"notes/sub/secret.md", false, false},
+		{"notes/sub/secret2.md", false, true},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, m.Ignored(tt.path, tt.isDir), "path %s", tt.path)
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		assert.False(t, m.Ignored("notes/other.md", false))
+		fsys["notes/.gitignore"] = &fstest.MapFile{Data: []byte("other.md\n")}
+		assert.False(t, m.Ignored("notes/other.md", false), "rules should be cached")
+		m.Reset("notes")
+		assert.True(t, m.Ignored("notes/other.md", false))
+	})
+
+	t.Run("ignore files", func(t *testing.T) {
+		assert.True(t, m.IsIgnoreFile("notes/.gitignore"))
+		assert.True(t, m.IsIgnoreFile(".linksyncerignore"))
+		assert.False(t, m.IsIgnoreFile("notes/gitignore.md"))
+	})
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// JSONLog writes records to the log file as JSON lines and sends them to the channel
+type JSONLog struct {
+	logger  *slog.Logger
+	file    io.Closer
+	channel chan Record
+	fields  map[string]any
+}
+
+// NewJSON creates a logger that writes JSON records with the time, level, message and fields
+func NewJSON(path string, channel chan Record, rotation Rotation) Logger {
+	l := &JSONLog{channel: channel}
+	if path != "" {
+		file, err := openRotatingFile(path, rotation)
+		if err != nil {
+			log.Fatalf("Unable to open log file. %s", err)
+			return nil
+		}
+		l.file = file
+		l.logger = slog.New(slog.NewJSONHandler(file, nil))
+	}
+	return l
+}
+
+func (l *JSONLog) log(level Level, format string, v ...any) {
+	r := Record{level, time.Now(), fmt.Sprintf(format, v...), l.fields}
+	send(l.channel, r)
+	if l.logger != nil {
+		l.logger.Log(context.Background(), slogLevel(level), r.Msg)
+	}
+}
+
+func (l *JSONLog) Error(format string, v ...any)   { l.log(Error, format, v...) }
+func (l *JSONLog) Warning(format string, v ...any) { l.log(Warning, format, v...) }
+func (l *JSONLog) Info(format string, v ...any)    { l.log(Info, format, v...) }
+
+func (l *JSONLog) With(args ...any) Logger {
+	logger := *l
+	logger.fields = toFields(l.fields, args)
+	if l.logger != nil {
+		logger.logger = l.logger.With(args...)
+	}
+	return &logger
+}
+
+func (l *JSONLog) Close() error {
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case Warning:
+		return slog.LevelWarn
+	case Error:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
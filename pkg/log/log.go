@@ -2,11 +2,19 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Formats of the log file
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 type Level int
 
 const (
@@ -15,22 +23,65 @@ const (
 	Error
 )
 
+func (l Level) String() string {
+	switch l {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "?"
+}
+
 type Record struct {
-	Level Level
-	Ts    time.Time
-	Msg   string
+	Level  Level
+	Ts     time.Time
+	Msg    string
+	Fields map[string]any // e.g. source file, old and new path, batch id
 }
 
 type Logger interface {
 	Error(format string, v ...any)
 	Warning(format string, v ...any)
 	Info(format string, v ...any)
+	// With returns a logger that adds the fields to every record.
+	// Arguments are key-value pairs, e.g. With("source", "notes/a.md").
+	With(args ...any) Logger
 	Close() error
 }
 
-func New(path string, channel chan Record) Logger {
+// FormatFields returns the fields as space-separated key=value pairs sorted by key
+func FormatFields(fields map[string]any) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// toFields converts key-value pairs into a map, merging them with the existing fields
+func toFields(fields map[string]any, args []any) map[string]any {
+	result := make(map[string]any, len(fields)+len(args)/2)
+	for k, v := range fields {
+		result[k] = v
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		result[fmt.Sprint(args[i])] = args[i+1]
+	}
+	return result
+}
+
+// New creates a logger that appends text records to the log file and sends them to the channel
+func New(path string, channel chan Record, rotation Rotation) Logger {
 	if path != "" {
-		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+		file, err := openRotatingFile(path, rotation)
 		if err != nil {
 			log.Fatalf("Unable to open log file. %s", err)
 			return nil
@@ -53,34 +104,48 @@ func New(path string, channel chan Record) Logger {
 
 type StdLog struct {
 	err, wrn, inf *log.Logger
-	file          *os.File
+	file          io.Closer
 	channel       chan Record
+	fields        map[string]any
 }
 
 func (l *StdLog) Error(format string, v ...any) {
 	msg := fmt.Sprintf(format, v...)
-	l.Send(Record{Error, time.Now(), msg})
+	l.Send(Record{Error, time.Now(), msg, l.fields})
 	if l.err != nil {
-		_ = l.err.Output(2, fmt.Sprintf(format, v...))
+		_ = l.err.Output(2, l.withFields(msg))
 	}
 }
 
 func (l *StdLog) Info(format string, v ...any) {
 	msg := fmt.Sprintf(format, v...)
-	l.Send(Record{Info, time.Now(), msg})
+	l.Send(Record{Info, time.Now(), msg, l.fields})
 	if l.inf != nil {
-		_ = l.inf.Output(2, msg)
+		_ = l.inf.Output(2, l.withFields(msg))
 	}
 }
 
 func (l *StdLog) Warning(format string, v ...any) {
 	msg := fmt.Sprintf(format, v...)
-	l.Send(Record{Warning, time.Now(), msg})
+	l.Send(Record{Warning, time.Now(), msg, l.fields})
 	if l.wrn != nil {
-		_ = l.wrn.Output(2, msg)
+		_ = l.wrn.Output(2, l.withFields(msg))
 	}
 }
 
+func (l *StdLog) With(args ...any) Logger {
+	logger := *l
+	logger.fields = toFields(l.fields, args)
+	return &logger
+}
+
+func (l *StdLog) withFields(msg string) string {
+	if len(l.fields) == 0 {
+		return msg
+	}
+	return msg + " " + FormatFields(l.fields)
+}
+
 func (l *StdLog) Close() error {
 	if l.file != nil {
 		return l.file.Close()
@@ -89,9 +154,19 @@ func (l *StdLog) Close() error {
 }
 
 func (l *StdLog) Send(entry Record) {
-	if l.channel != nil {
+	send(l.channel, entry)
+}
+
+func send(channel chan Record, entry Record) {
+	if channel == nil {
+		return
+	}
+	// keep the order of the records while the channel has free space
+	select {
+	case channel <- entry:
+	default:
 		go func() {
-			l.channel <- entry
+			channel <- entry
 		}()
 	}
 }
@@ -103,4 +178,5 @@ func NewEmptyLog() Logger { return EmptyLog{} }
 func (l EmptyLog) Error(string, ...any)   {}
 func (l EmptyLog) Warning(string, ...any) {}
 func (l EmptyLog) Info(string, ...any)    {}
+func (l EmptyLog) With(...any) Logger     { return l }
 func (l EmptyLog) Close() error           { return nil }
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "linksyncer.log")
+	records := make(chan Record, 2)
+	logger := NewJSON(path, records, Rotation{})
+
+	logger.With("batch", "b1").With("source", "notes/a.md").Info("Links updated: %s", "notes/a.md")
+	logger.Warning("Index is outdated")
+	assert.NoError(t, logger.Close())
+
+	r := <-records
+	assert.Equal(t, Info, r.Level)
+	assert.Equal(t, "Links updated: notes/a.md", r.Msg)
+	assert.Equal(t, map[string]any{"batch": "b1", "source": "notes/a.md"}, r.Fields)
+	r = <-records
+	assert.Equal(t, Warning, r.Level)
+	assert.Empty(t, r.Fields)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Contains(t, entry, "time")
+	delete(entry, "time")
+	assert.Equal(t, map[string]any{
+		"level":  "INFO",
+		"msg":    "Links updated: notes/a.md",
+		"batch":  "b1",
+		"source": "notes/a.md",
+	}, entry)
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+}
+
+func TestStdLogFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "linksyncer.log")
+	logger := New(path, nil, Rotation{})
+	logger.With("to", "b.md", "from", "a.md").Info("File moved")
+	assert.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "File moved from=a.md to=b.md\n"), string(data))
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Rotation configures rotation of the log file. Zero values disable the limits.
+type Rotation struct {
+	MaxSize  int64         // maximum size of the log file in bytes
+	MaxAge   time.Duration // maximum age of the log file
+	MaxFiles int           // number of rotated files to keep
+}
+
+// rotatingFile appends to the log file and rotates it when it exceeds the size or the age.
+// Rotated files are renamed to path.1, path.2... with path.1 being the most recent one.
+type rotatingFile struct {
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	started  time.Time // the age of an existing file is counted from its last modification
+	closed   bool
+	mu       sync.Mutex
+}
+
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = fi.Size()
+	f.started = time.Now()
+	if f.size > 0 {
+		f.started = fi.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if f.size > 0 && f.shouldRotate(len(p)) {
+		rotateErr = f.rotate()
+	}
+	// if the rotation failed, logging continues into the file at the original path,
+	// and the rotation is retried on the next write
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, errors.Join(rotateErr, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil && rotateErr != nil {
+		err = fmt.Errorf("couldn't rotate log file: %w", rotateErr)
+	}
+	return n, err
+}
+
+func (f *rotatingFile) shouldRotate(n int) bool {
+	r := f.rotation
+	return (r.MaxSize > 0 && f.size+int64(n) > r.MaxSize) ||
+		(r.MaxAge > 0 && time.Since(f.started) >= r.MaxAge)
+}
+
+// rotate renames the current file to path.1, shifts the older files and removes the ones over the limit
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	name := func(i int) string { return fmt.Sprintf("%s.%d", f.path, i) }
+
+	for i := f.rotation.MaxFiles + 1; ; i++ { // files over the limit, e.g. after the limit was lowered
+		if err := os.Remove(name(i)); err != nil {
+			break
+		}
+	}
+	if f.rotation.MaxFiles == 0 {
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+		return f.open()
+	}
+	for i := f.rotation.MaxFiles - 1; i > 0; i-- {
+		if err := os.Rename(name(i), name(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, name(1)); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
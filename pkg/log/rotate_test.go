@@ -0,0 +1,94 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Run("append", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		assert.NoError(t, os.WriteFile(path, []byte("old\n"), 0644))
+		f, err := openRotatingFile(path, Rotation{})
+		assert.NoError(t, err)
+		_, err = f.Write([]byte("new\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "old\nnew\n", readLog(t, path))
+	})
+
+	t.Run("size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		f, err := openRotatingFile(path, Rotation{MaxSize: 8, MaxFiles: 2})
+		assert.NoError(t, err)
+		for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n"} {
+			_, err = f.Write([]byte(line))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "line4\n", readLog(t, path))
+		assert.Equal(t, "line3\n", readLog(t, path+".1"))
+		assert.Equal(t, "line2\n", readLog(t, path+".2"))
+		assert.NoFileExists(t, path+".3")
+	})
+
+	t.Run("age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		assert.NoError(t, os.WriteFile(path, []byte("old\n"), 0644))
+		old := time.Now().Add(-2 * time.Hour)
+		assert.NoError(t, os.Chtimes(path, old, old))
+		f, err := openRotatingFile(path, Rotation{MaxAge: time.Hour, MaxFiles: 1})
+		assert.NoError(t, err)
+		_, err = f.Write([]byte("new\n"))
+		assert.NoError(t, err)
+		_, err = f.Write([]byte("new2\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "new\nnew2\n", readLog(t, path))
+		assert.Equal(t, "old\n", readLog(t, path+".1"))
+	})
+
+	t.Run("failed rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		// a directory in place of the rotated file makes the rename fail
+		assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "dir"), 0755))
+		f, err := openRotatingFile(path, Rotation{MaxSize: 4, MaxFiles: 1})
+		assert.NoError(t, err)
+		_, err = f.Write([]byte("abc\n"))
+		assert.NoError(t, err)
+		n, err := f.Write([]byte("def\n"))
+		assert.Error(t, err)
+		assert.Equal(t, 4, n, "the record should be written to the current file")
+		_, _ = f.Write([]byte("ghi\n"))
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "abc\ndef\nghi\n", readLog(t, path))
+
+		_, err = f.Write([]byte("closed\n"))
+		assert.ErrorIs(t, err, os.ErrClosed)
+	})
+
+	t.Run("no retained files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		assert.NoError(t, os.WriteFile(path+".1", []byte("stale\n"), 0644))
+		f, err := openRotatingFile(path, Rotation{MaxSize: 4})
+		assert.NoError(t, err)
+		_, _ = f.Write([]byte("abc\n"))
+		_, _ = f.Write([]byte("def\n"))
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "def\n", readLog(t, path))
+		assert.NoFileExists(t, path+".1")
+	})
+}
@@ -44,7 +44,7 @@ func (p *Parser) Block(data []byte) {
 		}
 
 		// anything else must look like a normal paragraph
-		// note: this finds underlined headings, too
+		// note: this finds headings, too
 		idx := p.paragraph(data)
 		data = data[idx:]
 	}
@@ -291,6 +291,20 @@ func (p *Parser) paragraph(data []byte) int {
 			return i + n
 		}
 
+		// an underline turns the paragraph into a setext heading
+		if i > 0 {
+			if level, end := setextUnderline(current); level > 0 {
+				p.setextHeading(data[:i], current[:end], level)
+				return i + end
+			}
+		}
+
+		// an ATX heading ends the paragraph and is a block itself
+		if end := p.atxHeading(current); end > 0 {
+			p.renderParagraph(data[:i])
+			return i + end
+		}
+
 		// if there's a fenced code block, paragraph is over
 		if p.fencedCodeBlock(current) > 0 {
 			p.renderParagraph(data[:i])
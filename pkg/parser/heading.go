@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// Headings returns ATX and setext headings
+func (p *Parser) Headings() []Heading {
+	headings := []Heading{}
+	for _, v := range p.Nodes {
+		if h, ok := v.(*Heading); ok {
+			headings = append(headings, *h)
+		}
+	}
+	return headings
+}
+
+// atxHeading parses a heading like "## Title ##" at the beginning of data
+// and returns the index of the next line, or 0 if there's no heading
+func (p *Parser) atxHeading(data []byte) int {
+	i := skipCharN(data, 0, ' ', 3)
+	level := skipChar(data, i, '#') - i
+	if level < 1 || level > 6 {
+		return 0
+	}
+	i += level
+	if i < len(data) && data[i] != ' ' && data[i] != '\t' && data[i] != '\n' {
+		return 0
+	}
+	end := skipUntilChar(data, i, '\n')
+
+	text := bytes.TrimRight(data[i:end], " \t")
+	// optional closing sequence: "## Title ##"
+	if closing := bytes.TrimRight(text, "#"); len(closing) == 0 || closing[len(closing)-1] == ' ' || closing[len(closing)-1] == '\t' {
+		text = closing
+	}
+	text = bytes.Trim(text, " \t")
+	p.addHeading(data[:end], text, level)
+	return skipCharN(data, end, '\n', 1)
+}
+
+// setextUnderline checks if there's a setext underline (=== or ---) at the beginning of data
+// and returns the heading level and the index of the next line, or zeros if there's none
+func setextUnderline(data []byte) (level, end int) {
+	i := skipCharN(data, 0, ' ', 3)
+	if i >= len(data) || (data[i] != '=' && data[i] != '-') {
+		return 0, 0
+	}
+	level = 1
+	if data[i] == '-' {
+		level = 2
+	}
+	i = skipChar(data, i, data[i])
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	if i < len(data) && data[i] != '\n' {
+		return 0, 0
+	}
+	return level, skipCharN(data, i, '\n', 1)
+}
+
+// setextHeading adds a heading made of the paragraph text and the underline
+func (p *Parser) setextHeading(para, underline []byte, level int) {
+	text := bytes.Trim(para, " \t\n")
+	content := underline
+	if start := p.offset(text); start >= 0 {
+		end := p.offset(underline) + len(bytes.TrimRight(underline, "\n"))
+		content = p.input[start:end]
+	}
+	p.addHeading(content, text, level)
+}
+
+// addHeading adds the heading node; the text is also parsed as a paragraph, so links in headings are found
+func (p *Parser) addHeading(content, text []byte, level int) {
+	p.AddBlock(&Paragraph{Content: text})
+
+	h := &Heading{Level: level, Range: noRange}
+	// explicit id: "Title {#custom-id}"
+	if bytes.HasSuffix(text, []byte("}")) {
+		if i := bytes.LastIndex(text, []byte("{#")); i >= 0 {
+			h.ID = text[i+2 : len(text)-1]
+			text = bytes.TrimRight(text[:i], " \t")
+		}
+	}
+	h.Leaf = Leaf{Literal: text, Content: content}
+	if start := p.offset(content); start >= 0 {
+		h.Range = p.span(start, start+len(content))
+	}
+	p.AppendNode(h)
+}
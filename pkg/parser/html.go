@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bytes"
+	"regexp"
 	"slices"
 
 	"golang.org/x/net/html"
@@ -25,6 +26,12 @@ func extractImgAndLinks(nodes []*html.Node) []*html.Node {
 	return result
 }
 
+// attrStart matches the attribute's name up to the beginning of its value: src="
+var attrStart = map[string]*regexp.Regexp{
+	"src":  regexp.MustCompile(`(?i)[\s"'/]src\s*=\s*["']?`),
+	"href": regexp.MustCompile(`(?i)[\s"'/]href\s*=\s*["']?`),
+}
+
 func (p *Parser) appendHTMLFragment(frag []byte) {
 	fakeBody := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
 
@@ -33,10 +40,33 @@ func (p *Parser) appendHTMLFragment(frag []byte) {
 		return
 	}
 
+	base := p.offset(frag)
+	nodeRange := noRange
+	if base >= 0 {
+		nodeRange = p.span(base, base+len(frag))
+	}
+	searchFrom := 0
+	// destRange looks for the attribute's value right after the attribute's name, so the same text
+	// in other attributes isn't matched. Values with character references can't be found,
+	// so their position remains unknown
+	destRange := func(key string, val []byte) Range {
+		if base < 0 {
+			return noRange
+		}
+		for _, m := range attrStart[key].FindAllIndex(frag[searchFrom:], -1) {
+			start := searchFrom + m[1]
+			if bytes.HasPrefix(frag[start:], val) {
+				searchFrom = start + len(val)
+				return p.span(base+start, base+searchFrom)
+			}
+		}
+		return noRange
+	}
+
 	for _, node := range extractImgAndLinks(entrNodes) {
 		switch node.DataAtom {
 		case atom.Img:
-			link := &Image{Leaf: Leaf{Content: frag}}
+			link := &Image{Leaf: Leaf{Content: frag}, Range: nodeRange}
 			for _, attr := range node.Attr {
 				if attr.Key == "src" {
 					link.Destination = []byte(attr.Val)
@@ -45,9 +75,10 @@ func (p *Parser) appendHTMLFragment(frag []byte) {
 			if len(link.Destination) == 0 {
 				continue
 			}
+			link.DestRange = destRange("src", link.Destination)
 			p.AppendNode(link)
 		case atom.A:
-			link := &Link{Leaf: Leaf{Content: frag}}
+			link := &Link{Leaf: Leaf{Content: frag}, Range: nodeRange}
 			for _, attr := range node.Attr {
 				if attr.Key == "href" {
 					link.Destination = []byte(attr.Val)
@@ -56,6 +87,7 @@ func (p *Parser) appendHTMLFragment(frag []byte) {
 			if len(link.Destination) == 0 {
 				continue
 			}
+			link.DestRange = destRange("href", link.Destination)
 			p.AppendNode(link)
 		}
 
@@ -155,6 +155,10 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 		return 0, nil
 	}
 
+	if consumed, node := wikiLink(p, data, offset); consumed > 0 {
+		return consumed, node
+	}
+
 	var t linkType
 	switch {
 	// ![alt] == image
@@ -166,12 +170,16 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 	}
 
 	data = data[offset:]
+	base := p.offset(data)
+	destB, destE := -1, -1 // position of the destination in data
 
 	var (
 		i                       = 1
 		title, link, altContent []byte
 		textHasNl               = false
 		refDefContent           []byte // save markdown content from reference definition
+		refDest                 = noRange
+		refID                   []byte
 	)
 
 	// look for the matching closing bracket
@@ -300,8 +308,10 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 		}
 
 		// build escaped link and title
+		destB, destE = linkB, linkB
 		if linkE > linkB {
 			link = data[linkB:linkE]
+			destE = linkE
 		}
 
 		if titleE > titleB {
@@ -356,6 +366,9 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 		link = lr.link
 		title = lr.title
 		refDefContent = lr.content
+		refDest = lr.dest
+		refID = id
+		lr.markUsage(t)
 		i++
 
 	// shortcut reference style link or reference or inline footnote
@@ -390,6 +403,9 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 		link = lr.link
 		// if inline footnote, title == footnote contents
 		title = lr.title
+		refDest = lr.dest
+		refID = id
+		lr.markUsage(t)
 
 		// rewind the whitespace
 		i = txtE + 1
@@ -418,6 +434,19 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 		content = data[:i]
 	}
 
+	// positions in the original input
+	nodeRange, destRange := noRange, refDest
+	if base >= 0 {
+		start := base
+		if t == linkImg {
+			start-- // include "!"
+		}
+		nodeRange = p.span(start, base+i)
+		if destB >= 0 {
+			destRange = p.span(base+destB, base+destE)
+		}
+	}
+
 	// call the relevant rendering function
 	switch t {
 	case linkNormal:
@@ -425,6 +454,9 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 			Destination: uLink,
 			Title:       title,
 			Leaf:        Leaf{Content: content},
+			RefID:       refID,
+			Range:       nodeRange,
+			DestRange:   destRange,
 		}
 		if len(altContent) > 0 {
 			p.AppendNode(newTextNode(altContent))
@@ -443,6 +475,9 @@ func link(p *Parser, data []byte, offset int) (int, Node) {
 			Destination: uLink,
 			Title:       title,
 			Leaf:        Leaf{Content: content},
+			RefID:       refID,
+			Range:       nodeRange,
+			DestRange:   destRange,
 		}
 		p.AppendNode(newTextNode(data[1:txtE]))
 		return i + 1, image
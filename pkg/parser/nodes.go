@@ -49,12 +49,30 @@ type HTMLSpan struct {
 	Leaf
 }
 
+// Range is a byte range [Start, End) in the parsed input.
+// Start is -1 if the position is unknown.
+type Range struct {
+	Start int
+	End   int
+}
+
+var noRange = Range{-1, -1}
+
+// Valid reports whether the position is known
+func (r Range) Valid() bool {
+	return r.Start >= 0 && r.End >= r.Start
+}
+
 // Link represents markdown link node
 type Link struct {
 	Leaf
 
 	Destination []byte // Destination is what goes into a href
 	Title       []byte // Title is the tooltip thing that goes in a title attribute
+	Wiki        bool   // Wiki is true for wiki-style links: [[target|alias]]
+	RefID       []byte // RefID is the id of the definition for reference-style links: [text][id]
+	Range       Range  // Range is the position of the whole link
+	DestRange   Range  // DestRange is the position of the raw destination, it's in the definition for reference-style links
 }
 
 // Image represents markdown image node
@@ -63,4 +81,29 @@ type Image struct {
 
 	Destination []byte // Destination is what goes into a href
 	Title       []byte // Title is the tooltip thing that goes in a title attribute
+	Wiki        bool   // Wiki is true for wiki-style embeds: ![[target]]
+	RefID       []byte // RefID is the id of the definition for reference-style images: ![alt][id]
+	Range       Range  // Range is the position of the whole image including "!"
+	DestRange   Range  // DestRange is the position of the raw destination, it's in the definition for reference-style links
+}
+
+// Reference represents a reference definition: [id]: destination "title"
+type Reference struct {
+	Leaf
+
+	ID          []byte
+	Destination []byte
+	Title       []byte
+	Image       bool  // Image is true if the definition is used by an image
+	Range       Range // Range is the position of the definition from the id to the destination
+	DestRange   Range // DestRange is the position of the raw destination
+}
+
+// Heading represents an ATX heading (# Title) or a setext heading (Title followed by === or ---)
+type Heading struct {
+	Leaf
+
+	Level int    // Level is the heading level from 1 to 6
+	ID    []byte // ID is the explicit anchor: # Title {#custom-id}
+	Range Range  // Range is the position of the whole heading including the markers
 }
@@ -5,6 +5,7 @@ Package parser implements parser for markdown text that generates AST (abstract
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +25,9 @@ type Parser struct {
 	maxNesting     int
 	insideLink     bool
 
+	input []byte // normalized input
+	crlf  []int  // positions in the normalized input where CRLF was replaced with LF
+
 	Blocks []Container
 	Nodes  []Node
 }
@@ -62,6 +66,17 @@ func (p *Parser) LinksAndImages() ([]Link, []Image) {
 	return links, images
 }
 
+// References returns reference definitions
+func (p *Parser) References() []Reference {
+	refs := []Reference{}
+	for _, v := range p.Nodes {
+		if ref, ok := v.(*Reference); ok {
+			refs = append(refs, *ref)
+		}
+	}
+	return refs
+}
+
 func (p *Parser) AppendNode(n Node) {
 	p.Nodes = append(p.Nodes, n)
 }
@@ -82,7 +97,8 @@ func (p *Parser) getRef(refid string) (ref *reference, found bool) {
 func (p *Parser) Parse(input []byte) {
 	// the code only works with Unix CR newlines so to make life easy for
 	// callers normalize newlines
-	input = NormalizeNewlines(input)
+	input, p.crlf = normalizeNewlines(input)
+	p.input = input
 	p.Block(input)
 	for _, block := range p.Blocks {
 		p.Inline(block.GetContent())
@@ -93,7 +109,16 @@ func (p *Parser) Parse(input []byte) {
 type reference struct {
 	link    []byte
 	title   []byte
-	content []byte // markdown content
+	content []byte     // markdown content
+	dest    Range      // position of the link
+	node    *Reference // node of the definition, nil for footnotes
+}
+
+// markUsage marks the definition as used by an image
+func (r *reference) markUsage(t linkType) {
+	if t == linkImg && r.node != nil {
+		r.node.Image = true
+	}
 }
 
 func (r *reference) String() string {
@@ -171,7 +196,33 @@ func isReference(p *Parser, data []byte, tabSize int) int {
 	ref := &reference{}
 	ref.link = data[linkOffset:linkEnd]
 	ref.title = data[titleOffset:titleEnd]
-	ref.content = data[:linkEnd]
+	// the definition ends after the closing angle bracket of the destination
+	contentEnd := linkEnd
+	if data[linkOffset-1] == '<' && linkEnd < len(data) && data[linkEnd] == '>' {
+		contentEnd++
+	}
+	ref.content = data[:contentEnd]
+	ref.dest = noRange
+	nodeRange := noRange
+	if base := p.offset(data); base >= 0 {
+		ref.dest = p.span(base+linkOffset, base+linkEnd)
+		nodeRange = p.span(base, base+contentEnd)
+	}
+
+	// footnotes aren't links
+	if data[idOffset] != '^' {
+		var dest bytes.Buffer
+		unescapeText(&dest, ref.link)
+		ref.node = &Reference{
+			ID:          data[idOffset:idEnd],
+			Destination: dest.Bytes(),
+			Title:       ref.title,
+			Leaf:        Leaf{Content: ref.content},
+			Range:       nodeRange,
+			DestRange:   ref.dest,
+		}
+		p.AppendNode(ref.node)
+	}
 
 	// id matches are case-insensitive
 	id := string(bytes.ToLower(data[idOffset:idEnd]))
@@ -182,18 +233,27 @@ func isReference(p *Parser, data []byte, tabSize int) int {
 }
 
 func scanLinkRef(p *Parser, data []byte, i int) (linkOffset, linkEnd, titleOffset, titleEnd, lineEnd int) {
-	// link: whitespace-free sequence, optionally between angle brackets
+	// link: whitespace-free sequence, or any sequence without newlines between angle brackets
 	if data[i] == '<' {
 		i++
-	}
-	linkOffset = i
-	for i < len(data) && data[i] != ' ' && data[i] != '\t' && data[i] != '\n' && data[i] != '\r' {
+		linkOffset = i
+		for i < len(data) && data[i] != '>' && data[i] != '\n' && data[i] != '\r' {
+			if data[i] == '\\' && i+1 < len(data) && data[i+1] == '>' { // escaped bracket
+				i++
+			}
+			i++
+		}
+		if i >= len(data) || data[i] != '>' {
+			return
+		}
+		linkEnd = i
 		i++
-	}
-	linkEnd = i
-	if linkEnd < len(data) && data[linkOffset] == '<' && data[linkEnd-1] == '>' {
-		linkOffset++
-		linkEnd--
+	} else {
+		linkOffset = i
+		for i < len(data) && data[i] != ' ' && data[i] != '\t' && data[i] != '\n' && data[i] != '\r' {
+			i++
+		}
+		linkEnd = i
 	}
 
 	// optional spacer: (space | tab)* (newline | '\'' | '"' | '(' )
@@ -275,7 +335,42 @@ func IsAlnum(c byte) bool {
 	return (c >= '0' && c <= '9') || IsLetter(c)
 }
 
+// offset returns position of the slice in the normalized input or -1 if it isn't a part of the input.
+// All slices created by the parser share the input's underlying array,
+// so the position can be calculated from the difference of capacities.
+func (p *Parser) offset(b []byte) int {
+	if p.input == nil {
+		return -1
+	}
+	off := cap(p.input) - cap(b)
+	if off < 0 || off > len(p.input) || len(b) > 0 && (off == len(p.input) || &p.input[off] != &b[0]) {
+		return -1
+	}
+	return off
+}
+
+// span converts positions in the normalized input to the range in the original input
+func (p *Parser) span(start, end int) Range {
+	if start < 0 || end < start {
+		return noRange
+	}
+	return Range{p.original(start), p.original(end)}
+}
+
+// original converts position in the normalized input to the position in the original input
+func (p *Parser) original(pos int) int {
+	return pos + sort.SearchInts(p.crlf, pos)
+}
+
 func NormalizeNewlines(d []byte) []byte {
+	d, _ = normalizeNewlines(d)
+	return d
+}
+
+// normalizeNewlines replaces CR and CRLF with LF and returns
+// positions in the result where CRLF were replaced
+func normalizeNewlines(d []byte) ([]byte, []int) {
+	crlf := []int{}
 	wi := 0
 	n := len(d)
 	for i := 0; i < n; i++ {
@@ -291,9 +386,10 @@ func NormalizeNewlines(d []byte) []byte {
 		wi++
 		if i < n-1 && d[i+1] == 10 {
 			// this was CRLF, so skip the LF
+			crlf = append(crlf, wi-1)
 			i++
 		}
 
 	}
-	return d[:wi]
+	return d[:wi], crlf
 }
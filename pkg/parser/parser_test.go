@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"strconv"
 	"testing"
 
@@ -88,6 +89,31 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, l, toLinkFlat(Link(got[0])))
 	})
 
+	t.Run("Reference definitions", func(t *testing.T) {
+		md := "![alt][img] [text][Note]\n\n[img]: ./image\\(1\\).png \"title\"\n[note]: <./my note.md> 'title'\n[unused]: ./other.md\n[^1]: footnote"
+
+		p := New()
+		p.Parse([]byte(md))
+		refs := p.References()
+		got := []linkFlat{}
+		for _, ref := range refs {
+			got = append(got, linkFlat{string(ref.Destination), string(ref.Title), string(ref.Content)})
+			assert.Equal(t, string(ref.ID), md[ref.Range.Start+1:ref.Range.Start+1+len(ref.ID)])
+		}
+		assert.Equal(t, []linkFlat{
+			{"./image(1).png", "title", "[img]: ./image\\(1\\).png"},
+			{"./my note.md", "title", "[note]: <./my note.md>"},
+			{"./other.md", "", "[unused]: ./other.md"},
+		}, got)
+		assert.Equal(t, []bool{true, false, false}, []bool{refs[0].Image, refs[1].Image, refs[2].Image})
+		assert.Equal(t, "./image\\(1\\).png", md[refs[0].DestRange.Start:refs[0].DestRange.End])
+		assert.Equal(t, "./my note.md", md[refs[1].DestRange.Start:refs[1].DestRange.End])
+
+		links, images := p.LinksAndImages()
+		assert.Equal(t, "Note", string(links[0].RefID))
+		assert.Equal(t, "img", string(images[0].RefID))
+	})
+
 	t.Run("html_link", func(t *testing.T) {
 		a := `<a href="./note2.md">`
 		md := "<p class=\"c\">" + a + "link</a></p>"
@@ -115,4 +141,153 @@ func TestParse(t *testing.T) {
 		}
 		assert.Equal(t, l, toLinkFlat(Link(got[0])))
 	})
+
+	t.Run("Wiki links", func(t *testing.T) {
+		tests := []struct {
+			md   string
+			want linkFlat
+		}{
+			{"[[Note Name]]", linkFlat{"Note Name", "", "[[Note Name]]"}},
+			{"text [[folder/note|alias]] text", linkFlat{"folder/note", "", "[[folder/note|alias]]"}},
+			{"[[note#heading]]", linkFlat{"note#heading", "", "[[note#heading]]"}},
+			{"| [[note\\|alias]] |", linkFlat{"note", "", "[[note\\|alias]]"}},
+		}
+
+		for i, tt := range tests {
+			t.Run(strconv.Itoa(i), func(t *testing.T) {
+				p := New()
+				p.Parse([]byte(tt.md))
+				got, _ := p.LinksAndImages()
+				if len(got) != 1 {
+					t.Fatalf("should be exactly one link, got %d", len(got))
+				}
+				assert.True(t, got[0].Wiki)
+				assert.Equal(t, tt.want, toLinkFlat(got[0]))
+			})
+		}
+	})
+
+	t.Run("Wiki embeds", func(t *testing.T) {
+		md := "![[diagram.png|300]]"
+		l := linkFlat{"diagram.png", "", "[[diagram.png|300]]"}
+
+		p := New()
+		p.Parse([]byte(md))
+		links, got := p.LinksAndImages()
+		if len(got) != 1 || len(links) != 0 {
+			t.Fatalf("should be exactly one image link, got %d", len(got))
+		}
+		assert.True(t, got[0].Wiki)
+		assert.Equal(t, l, toLinkFlat(Link(got[0])))
+	})
+}
+
+func TestPositions(t *testing.T) {
+	md := "# Title\r\n\r\nText [link](<./some file.md> \"title\") and ![img](a\\(1\\).png).\r\n" +
+		"[ref link][ref] ![[embed.png|200]] [[note#head|alias]]\r\n" +
+		"<img src=\"./html.png\" />\r\n\r\n" +
+		"[ref]: ./ref.md \"title\""
+	input := []byte(md)
+
+	p := New()
+	p.Parse([]byte(md))
+
+	type position struct {
+		node string
+		dest string
+	}
+	got := []position{}
+	for _, n := range p.Nodes {
+		var r, d Range
+		switch n := n.(type) {
+		case *Link:
+			r, d = n.Range, n.DestRange
+		case *Image:
+			r, d = n.Range, n.DestRange
+		default:
+			continue
+		}
+		if !r.Valid() || !d.Valid() {
+			t.Fatalf("unknown position of %s", n.GetContent())
+		}
+		got = append(got, position{string(input[r.Start:r.End]), string(input[d.Start:d.End])})
+	}
+
+	assert.Equal(t, []position{
+		{"[link](<./some file.md> \"title\")", "./some file.md"},
+		{"![img](a\\(1\\).png)", "a\\(1\\).png"},
+		{"[ref link][ref]", "./ref.md"},
+		{"![[embed.png|200]]", "embed.png"},
+		{"[[note#head|alias]]", "note#head"},
+		{"<img src=\"./html.png\" />", "./html.png"},
+	}, got)
+}
+
+func TestHeadings(t *testing.T) {
+	md := "# Title #\n" +
+		"text\n" +
+		"## Install [steps](./steps.md) {#install}\n" +
+		"#hashtag\n\n" +
+		"Setext\n======\n\n" +
+		"Second\nline\n---\n\n" +
+		"```\n# not a heading\n```\n" +
+		"   ### C#"
+	input := []byte(md)
+
+	p := New()
+	p.Parse([]byte(md))
+
+	type heading struct {
+		level   int
+		text    string
+		id      string
+		content string
+	}
+	got := []heading{}
+	for _, h := range p.Headings() {
+		if !h.Range.Valid() {
+			t.Fatalf("unknown position of %s", h.GetContent())
+		}
+		got = append(got, heading{h.Level, string(h.GetLiteral()), string(h.ID), string(input[h.Range.Start:h.Range.End])})
+	}
+	assert.Equal(t, []heading{
+		{1, "Title", "", "# Title #"},
+		{2, "Install [steps](./steps.md)", "install", "## Install [steps](./steps.md) {#install}"},
+		{1, "Setext", "", "Setext\n======"},
+		{2, "Second\nline", "", "Second\nline\n---"},
+		{3, "C#", "", "   ### C#"},
+	}, got)
+
+	links, _ := p.LinksAndImages()
+	if assert.Len(t, links, 1, "links in headings should be parsed") {
+		assert.Equal(t, "./steps.md", string(links[0].Destination))
+	}
+}
+
+func TestHTMLPositions(t *testing.T) {
+	md := `<img alt="img.png" src="img.png"> <img title='a.png' SRC = 'a.png'>` + "\n" +
+		`<a title="note.md" href="note.md">note</a>`
+	input := []byte(md)
+
+	p := New()
+	p.Parse([]byte(md))
+
+	got := []string{}
+	for _, n := range p.Nodes {
+		var d Range
+		switch n := n.(type) {
+		case *Link:
+			d = n.DestRange
+		case *Image:
+			d = n.DestRange
+		default:
+			continue
+		}
+		if !d.Valid() {
+			t.Fatalf("unknown position of %s", n.GetContent())
+		}
+		// the attribute before the value shows which one was found
+		got = append(got, string(input[bytes.LastIndexAny(input[:d.Start], " ")+1:d.End]))
+	}
+	assert.Equal(t, []string{`src="img.png`, `'a.png`, `href="note.md`}, got)
 }
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// '[' or '!': parse a wiki-style link "[[target|alias]]" or an embed "![[target]]"
+func wikiLink(p *Parser, data []byte, offset int) (int, Node) {
+	isEmbed := data[offset] == '!'
+	if isEmbed {
+		offset++
+	}
+	data = data[offset:]
+
+	if len(data) < 5 || data[0] != '[' || data[1] != '[' {
+		return 0, nil
+	}
+
+	end := bytes.Index(data[2:], []byte("]]"))
+	if end < 0 {
+		return 0, nil
+	}
+	end += 2
+
+	inner := data[2:end]
+	// wiki links can't span multiple lines or contain brackets
+	if bytes.ContainsAny(inner, "[]\n") {
+		return 0, nil
+	}
+
+	// everything after the pipe is an alias (or a size for embeds)
+	target := inner
+	if i := bytes.IndexByte(inner, '|'); i >= 0 {
+		target = inner[:i]
+		// pipe can be escaped inside tables: [[note\|alias]]
+		if i > 0 && inner[i-1] == '\\' {
+			target = inner[:i-1]
+		}
+	}
+	target = bytes.TrimSpace(target)
+	if len(target) == 0 {
+		return 0, nil
+	}
+
+	i := end + 2
+	// "[[text]](url)" is a normal link with brackets in the text
+	if i < len(data) && data[i] == '(' {
+		return 0, nil
+	}
+	content := data[:i]
+
+	nodeRange, destRange := noRange, noRange
+	if base := p.offset(data); base >= 0 {
+		start := base
+		if isEmbed {
+			start--
+		}
+		nodeRange = p.span(start, base+i)
+		destStart := p.offset(target)
+		destRange = p.span(destStart, destStart+len(target))
+	}
+
+	if isEmbed {
+		image := &Image{
+			Destination: target,
+			Wiki:        true,
+			Leaf:        Leaf{Content: content},
+			Range:       nodeRange,
+			DestRange:   destRange,
+		}
+		return i + 1, image
+	}
+
+	link := &Link{
+		Destination: target,
+		Wiki:        true,
+		Leaf:        Leaf{Content: content},
+		Range:       nodeRange,
+		DestRange:   destRange,
+	}
+	return i, link
+}
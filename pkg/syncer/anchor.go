@@ -0,0 +1,117 @@
+package syncer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	mdParser "github.com/flytaly/linksyncer/pkg/parser"
+)
+
+// splitDest splits the destination of a markdown link into the path and the query string with the fragment.
+// Destinations that start with "#" point to a heading in the same note and aren't split.
+func splitDest(dest string) (path, suffix string) {
+	if strings.HasPrefix(dest, "#") {
+		return dest, ""
+	}
+	if i := strings.IndexAny(dest, "?#"); i >= 0 {
+		return dest[:i], dest[i:]
+	}
+	return dest, ""
+}
+
+// destAnchor returns the fragment of the markdown link's destination without "#"
+func destAnchor(dest string) string {
+	if i := strings.IndexByte(dest, '#'); i >= 0 {
+		return dest[i+1:]
+	}
+	return ""
+}
+
+// wikiAnchor returns the heading of the wiki link's target: [[note#Heading]].
+// Only the last heading is used for nested headings: [[note#Heading#Subheading]].
+func wikiAnchor(heading string) string {
+	if i := strings.LastIndexByte(heading, '#'); i >= 0 {
+		return strings.TrimSpace(heading[i+1:])
+	}
+	return ""
+}
+
+// inlineLink matches markdown links and images in the heading's text
+var inlineLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// headingSlug converts the heading's text into an anchor the same way GitHub does:
+// letters are lowercased, spaces are replaced with "-" and punctuation is removed
+func headingSlug(text string) string {
+	text = inlineLink.ReplaceAllString(text, "$1")
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ' || r == '\n':
+			sb.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// anchors is a set of the headings' anchors in a note
+type anchors map[string]bool
+
+// noteAnchors returns anchors of the headings in the note's content.
+// Repeated headings get numeric suffixes: "usage", "usage-1", "usage-2".
+func noteAnchors(content []byte) anchors {
+	p := mdParser.New()
+	p.Parse(content)
+	result := anchors{}
+	for _, h := range p.Headings() {
+		if len(h.ID) > 0 {
+			result[string(h.ID)] = true
+			continue
+		}
+		slug := headingSlug(string(h.GetLiteral()))
+		anchor := slug
+		for i := 1; result[anchor]; i++ {
+			anchor = fmt.Sprintf("%s-%d", slug, i)
+		}
+		result[anchor] = true
+	}
+	return result
+}
+
+// has reports whether the anchor of a link points to one of the headings.
+// The anchor can be written as a slug (#install-steps) or as the heading text ([[note#Install steps]]).
+func (a anchors) has(anchor string) bool {
+	anchor = decodePath(anchor)
+	return a[anchor] || a[headingSlug(anchor)]
+}
+
+// checkedAnchor returns the note and the anchor that should exist in it,
+// or false if the link's anchor shouldn't be checked
+func (s *LinkSyncer) checkedAnchor(source string, link LinkInfo) (string, string, bool) {
+	// empty anchors point to the top of the note, block references (#^id) aren't headings
+	if link.anchor == "" || strings.HasPrefix(link.anchor, "^") {
+		return "", "", false
+	}
+	if strings.HasPrefix(link.path, "#") {
+		return source, link.anchor, true
+	}
+	return link.rootPath, link.anchor, s.isParsable(link.rootPath)
+}
+
+// readLinked reads the linked file that can be outside of the root directory
+func (s *LinkSyncer) readLinked(rootPath string) ([]byte, error) {
+	switch {
+	case filepath.IsAbs(rootPath):
+		return os.ReadFile(rootPath)
+	case fs.ValidPath(rootPath):
+		return s.ReadFile(rootPath)
+	default: // outside of the root directory
+		return os.ReadFile(filepath.Join(s.root, rootPath))
+	}
+}
@@ -0,0 +1,137 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupDir is the default directory for copies of the rewritten notes, relative to the watched directory
+const BackupDir = ".linksyncer/backups"
+
+// backupTimeFormat is the suffix of the backup files, it keeps them sorted by time
+const backupTimeFormat = "20060102T150405.000000"
+
+// atomicWriteFile writes data to a temporary file in the same directory, syncs it to the disk
+// and renames it over the original, so the file is never left truncated.
+// Symlinks are resolved, so the target is rewritten instead of the link being replaced.
+func atomicWriteFile(absPath string, data []byte, mode os.FileMode) (err error) {
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	}
+	dir, name := filepath.Split(absPath)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode.Perm()); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), absPath); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the directory entry after rename. Errors are ignored,
+// since not every platform supports syncing directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	d.Close()
+}
+
+// backupPath returns path to the directory with backups
+func (s *LinkSyncer) backupPath() string {
+	if filepath.IsAbs(s.BackupDir) {
+		return s.BackupDir
+	}
+	return filepath.Join(s.root, s.BackupDir)
+}
+
+// backup saves the content of the note before it's rewritten.
+// Without the backup directory, the copy is saved next to the note with the ".bak" extension.
+// Otherwise, the last Backups copies of the note are kept in the backup directory.
+func (s *LinkSyncer) backup(relativePath string, content []byte) error {
+	if s.Backups <= 0 {
+		return nil
+	}
+	if s.BackupDir == "" {
+		return writeBackup(filepath.Join(s.root, relativePath+".bak"), content)
+	}
+
+	target := filepath.Join(s.backupPath(), relativePath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	err := writeBackup(fmt.Sprintf("%s.%s", target, time.Now().Format(backupTimeFormat)), content)
+	if err != nil {
+		return err
+	}
+	return pruneBackups(target, s.Backups)
+}
+
+func writeBackup(path string, content []byte) error {
+	return atomicWriteFile(path, content, 0644)
+}
+
+// pruneBackups removes all but the last n backups of the file
+func pruneBackups(target string, n int) error {
+	backups, err := listBackups(target)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= n {
+		return nil
+	}
+	for _, b := range backups[:len(backups)-n] {
+		if err = os.Remove(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listBackups returns paths to the backups of the file sorted from the oldest
+func listBackups(target string) ([]string, error) {
+	dir, name := filepath.Split(target)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := []string{}
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), name+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, suffix); err != nil {
+			continue // backup of another file, e.g. "note.md.md"
+		}
+		backups = append(backups, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
@@ -0,0 +1,103 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "note.md")
+	writeTestFiles(t, root, map[string]string{"note.md": "old content"})
+	assert.NoError(t, os.Chmod(path, 0600))
+
+	assert.NoError(t, writeFile(path, []byte("new")))
+	assert.Equal(t, "new", readTestFile(t, path))
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should be renamed")
+
+	assert.Error(t, writeFile(filepath.Join(root, "missing.md"), []byte("new")))
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{"notes/real.md": "x"})
+	link := filepath.Join(root, "link.md")
+	if err := os.Symlink(filepath.Join("notes", "real.md"), link); err != nil {
+		t.Skipf("symlinks aren't supported: %v", err)
+	}
+
+	assert.NoError(t, writeFile(link, []byte("new")))
+	fi, err := os.Lstat(link)
+	assert.NoError(t, err)
+	assert.True(t, fi.Mode()&os.ModeSymlink != 0, "symlink shouldn't be replaced")
+	assert.Equal(t, "new", readTestFile(t, filepath.Join(root, "notes/real.md")))
+
+	entries, err := os.ReadDir(filepath.Join(root, "notes"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should be renamed")
+}
+
+func TestBackups(t *testing.T) {
+	setup := func(t *testing.T, backupDir string) (*LinkSyncer, string) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{
+			"notes/note.md":    "![](../a.png)",
+			"notes/note.md.md": "![](../a.png)",
+			"a.png":            "png",
+		})
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.Backups = 2
+			s.BackupDir = backupDir
+		})
+		iSync.ProcessFiles()
+		return iSync, root
+	}
+	move := func(t *testing.T, iSync *LinkSyncer, root, from, to string) {
+		t.Helper()
+		assert.NoError(t, os.Rename(filepath.Join(root, from), filepath.Join(root, to)))
+		iSync.Sync(map[string]string{from: to})
+	}
+
+	t.Run("backup directory", func(t *testing.T) {
+		iSync, root := setup(t, BackupDir)
+		move(t, iSync, root, "a.png", "b.png")
+		move(t, iSync, root, "b.png", "c.png")
+		move(t, iSync, root, "c.png", "d.png")
+		assert.Equal(t, "![](../d.png)", readTestFile(t, filepath.Join(root, "notes/note.md")))
+
+		backups, err := listBackups(filepath.Join(root, BackupDir, "notes/note.md"))
+		assert.NoError(t, err)
+		assert.Len(t, backups, 2, "only the last backups should be kept")
+		assert.Equal(t, "![](../b.png)", readTestFile(t, backups[0]))
+		assert.Equal(t, "![](../c.png)", readTestFile(t, backups[1]))
+
+		backups, err = listBackups(filepath.Join(root, BackupDir, "notes/note.md.md"))
+		assert.NoError(t, err)
+		assert.Len(t, backups, 2)
+	})
+
+	t.Run("bak file", func(t *testing.T) {
+		iSync, root := setup(t, "")
+		move(t, iSync, root, "a.png", "b.png")
+		move(t, iSync, root, "b.png", "c.png")
+		assert.Equal(t, "![](../b.png)", readTestFile(t, filepath.Join(root, "notes/note.md.bak")))
+		assert.NoDirExists(t, filepath.Join(root, BackupDir))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		iSync, root := setup(t, BackupDir)
+		iSync.Backups = 0
+		move(t, iSync, root, "a.png", "b.png")
+		assert.NoDirExists(t, filepath.Join(root, BackupDir))
+		assert.NoFileExists(t, filepath.Join(root, "notes/note.md.bak"))
+	})
+}
@@ -0,0 +1,118 @@
+package syncer
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BrokenLink is a link to a file that doesn't exist or to a heading that doesn't exist in the linked note
+type BrokenLink struct {
+	Source string // path to the note
+	Dest   string // destination as it's written in the note, without the query string and the fragment
+	Target string // resolved path to the linked file
+	Anchor string // missing heading anchor, empty if the file doesn't exist
+	Line   int    // 1-based line number
+	Column int    // 1-based column in bytes
+}
+
+// exists checks if the linked file exists
+func (s *LinkSyncer) exists(rootPath string) bool {
+	var err error
+	switch {
+	case filepath.IsAbs(rootPath):
+		_, err = os.Stat(rootPath)
+	case fs.ValidPath(rootPath):
+		_, err = fs.Stat(s.fileSystem, rootPath)
+	default: // outside of the root directory
+		_, err = os.Stat(filepath.Join(s.root, rootPath))
+	}
+	return err == nil
+}
+
+// Check returns links to files or headings that don't exist sorted by source file and position
+func (s *LinkSyncer) Check() []BrokenLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []BrokenLink{}
+	cache := map[string]anchors{} // anchors of the already read notes
+	for source, links := range s.Sources {
+		var nodes []ContentLink
+		var content []byte
+		read := false
+		found := map[int]bool{} // positions of the already reported links
+		for _, link := range links {
+			broken := BrokenLink{Source: source, Dest: link.path, Target: link.rootPath}
+			switch {
+			case !strings.HasPrefix(link.path, "#") && !s.exists(link.rootPath):
+				// the linked file doesn't exist
+			case s.missingAnchor(source, link, cache):
+				broken.Anchor = link.anchor
+				if strings.HasPrefix(link.path, "#") {
+					broken.Target = source
+				}
+			default:
+				continue
+			}
+			if !read {
+				data, err := s.ReadFile(source)
+				if err != nil {
+					s.log.Error("Couldn't read file. %s", err)
+				}
+				content, read = data, true
+				linkNodes, imageNodes := contentLinks(string(content))
+				nodes = append(linkNodes, imageNodes...)
+			}
+			if n, ok := findLink(nodes, link, found); ok && n.pos.Valid() {
+				broken.Line, broken.Column = lineColumn(content, n.pos.Start)
+				found[n.pos.Start] = true
+			}
+			result = append(result, broken)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return result
+}
+
+// missingAnchor reports whether the link points to a heading that doesn't exist in the linked note.
+// Anchors of the read notes are saved in the cache.
+func (s *LinkSyncer) missingAnchor(source string, link LinkInfo, cache map[string]anchors) bool {
+	note, anchor, ok := s.checkedAnchor(source, link)
+	if !ok {
+		return false
+	}
+	headings, ok := cache[note]
+	if !ok {
+		content, err := s.readLinked(note)
+		if err != nil {
+			s.log.With("source", note).Error("Couldn't read file. %s", err)
+		} else {
+			headings = noteAnchors(content)
+		}
+		cache[note] = headings
+	}
+	// anchors of the notes that couldn't be read aren't reported
+	return headings != nil && !headings.has(anchor)
+}
+
+// lineColumn converts byte offset to 1-based line and column numbers
+func lineColumn(content []byte, offset int) (line, column int) {
+	before := content[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
@@ -0,0 +1,50 @@
+package syncer
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	var fs = fstest.MapFS{
+		"index.md":         {Data: []byte("# Index\n[ok](notes/note.md)\n\n![](img.png) ![](missing.png)\n[[Missing note]] [x](#heading)")},
+		"notes/note.md":    {Data: []byte("text ![](../img.png) [](./gone.md)\n![](../missing.png)")},
+		"notes/valid.md":   {Data: []byte("[[note]]")},
+		"img.png":          {Data: []byte("")},
+		"docs/unknown.pdf": {Data: []byte("")},
+	}
+	iSync := NewTestISync(fs, ".")
+	iSync.ProcessFiles()
+
+	want := []BrokenLink{
+		{Source: "index.md", Dest: "missing.png", Target: "missing.png", Line: 4, Column: 14},
+		{Source: "index.md", Dest: "Missing note", Target: "Missing note.md", Line: 5, Column: 1},
+		{Source: "index.md", Dest: "#heading", Target: "index.md", Anchor: "heading", Line: 5, Column: 18},
+		{Source: "notes/note.md", Dest: "./gone.md", Target: "notes/gone.md", Line: 1, Column: 22},
+		{Source: "notes/note.md", Dest: "../missing.png", Target: "missing.png", Line: 2, Column: 1},
+	}
+	assert.Equal(t, want, iSync.Check())
+}
+
+func TestCheckAnchors(t *testing.T) {
+	var fs = fstest.MapFS{
+		"guide.md": {Data: []byte("# Guide\n## Install steps\n\nUsage\n---\n## Usage\n### Custom {#custom-id}\n[top](#guide) [gone](#gone)")},
+		"index.md": {Data: []byte("[ok](guide.md#install-steps) [ok](guide.md#usage-1) [ok](guide.md?raw=true#custom-id)\n" +
+			"[missing](guide.md#uninstall) [[guide#Install steps]] [[guide#Removed]] [[guide#^block]]\n" +
+			"![](img.png#center) [](notes.pdf#page=2) [](missing.md#heading)")},
+		"img.png":   {Data: []byte("")},
+		"notes.pdf": {Data: []byte("")},
+	}
+	iSync := NewTestISync(fs, ".")
+	iSync.ProcessFiles()
+
+	want := []BrokenLink{
+		{Source: "guide.md", Dest: "#gone", Target: "guide.md", Anchor: "gone", Line: 8, Column: 15},
+		{Source: "index.md", Dest: "guide.md", Target: "guide.md", Anchor: "uninstall", Line: 2, Column: 1},
+		{Source: "index.md", Dest: "guide", Target: "guide.md", Anchor: "Removed", Line: 2, Column: 55},
+		{Source: "index.md", Dest: "missing.md", Target: "missing.md", Line: 3, Column: 42},
+	}
+	assert.Equal(t, want, iSync.Check())
+}
@@ -0,0 +1,107 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// ErrConflict means the note was modified after it was indexed,
+// so its links weren't updated to avoid overwriting the changes
+var ErrConflict = errors.New("note was modified during synchronization")
+
+// checkUnchanged returns ErrConflict if the content read before the rewrite differs from the indexed one
+func (s *LinkSyncer) checkUnchanged(relativePath string, content []byte) error {
+	entry, ok := s.index[relativePath]
+	if ok && entry.Hash != hashContent(content) {
+		return fmt.Errorf("%w: %s", ErrConflict, relativePath)
+	}
+	return nil
+}
+
+// checkStamp returns ErrConflict if the file was written after it was read, i.e. its stamp has changed
+func (s *LinkSyncer) checkStamp(relativePath string, stamp fs.FileInfo) error {
+	fi, err := fs.Stat(s.fileSystem, relativePath)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != stamp.Size() || !fi.ModTime().Equal(stamp.ModTime()) {
+		return fmt.Errorf("%w: %s", ErrConflict, relativePath)
+	}
+	return nil
+}
+
+// addConflict saves the links that couldn't be updated, so the update can be retried
+func (s *LinkSyncer) addConflict(relativePath string, movedLinks []MovedLink) {
+	saved := s.conflicts[relativePath]
+	for _, ml := range movedLinks {
+		found := false
+		for i, c := range saved {
+			if c.link.fullLink == ml.link.fullLink && c.link.path == ml.link.path {
+				saved[i] = ml
+				found = true
+			}
+		}
+		if !found {
+			saved = append(saved, ml)
+		}
+	}
+	s.conflicts[relativePath] = saved
+}
+
+// moveConflict keeps the conflict of the moved note
+func (s *LinkSyncer) moveConflict(oldPath, newPath string) {
+	if links, ok := s.conflicts[oldPath]; ok {
+		delete(s.conflicts, oldPath)
+		s.conflicts[newPath] = links
+	}
+}
+
+// Conflicts returns sorted paths of the notes that weren't updated because they were modified
+func (s *LinkSyncer) Conflicts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths := []string{}
+	for path := range s.conflicts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// RetryConflicts reads the conflicting notes again and updates their links.
+// Notes that were deleted since are forgotten.
+func (s *LinkSyncer) RetryConflicts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beginBatch(nil)
+	defer s.commitBatch()
+	conflicts := s.conflicts
+	s.conflicts = map[string][]MovedLink{}
+	for path, movedLinks := range conflicts {
+		if _, ok := s.Sources[path]; !ok {
+			continue
+		}
+		for _, li := range s.Sources[path] {
+			s.clearLinkReferences(path, li.rootPath)
+		}
+		s.AddFile(path) // index the current content
+		s.updateLinks(path, movedLinks)
+	}
+}
+
+// updateLinks updates links in the file, reports errors and returns true if the file was updated
+func (s *LinkSyncer) updateLinks(relativePath string, movedLinks []MovedLink) bool {
+	err := s.UpdateLinksInFile(relativePath, movedLinks)
+	if errors.Is(err, ErrConflict) {
+		s.addConflict(relativePath, movedLinks)
+		s.logger().With("source", relativePath).Warning("Links in %s weren't updated: the note was modified during synchronization", relativePath)
+		return false
+	}
+	if err != nil {
+		s.logger().With("source", relativePath).Error("Couldn't update links in %s. Error: %v", relativePath, err)
+		return false
+	}
+	return true
+}
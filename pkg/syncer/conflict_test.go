@@ -0,0 +1,68 @@
+package syncer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflicts(t *testing.T) {
+	setup := func(t *testing.T) (*LinkSyncer, string) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{
+			"index.md":  "![](img.png)",
+			"note.md":   "![](img.png)",
+			"img.png":   "png",
+			"other.png": "png",
+		})
+		iSync := New(os.DirFS(root), root, nil)
+		iSync.ProcessFiles()
+		return iSync, root
+	}
+
+	t.Run("skip modified notes", func(t *testing.T) {
+		iSync, root := setup(t)
+		// the note is saved by an editor before the move is synchronized
+		writeTestFiles(t, root, map[string]string{"note.md": "![](img.png)\nnew line"})
+		assert.NoError(t, os.Rename(filepath.Join(root, "img.png"), filepath.Join(root, "moved.png")))
+		iSync.Sync(map[string]string{"img.png": "moved.png"})
+
+		assert.Equal(t, "![](moved.png)", readTestFile(t, filepath.Join(root, "index.md")))
+		assert.Equal(t, "![](img.png)\nnew line", readTestFile(t, filepath.Join(root, "note.md")), "edits shouldn't be lost")
+		assert.Equal(t, []string{"note.md"}, iSync.Conflicts())
+
+		iSync.RetryConflicts()
+		assert.Equal(t, "![](moved.png)\nnew line", readTestFile(t, filepath.Join(root, "note.md")))
+		assert.Empty(t, iSync.Conflicts())
+		assert.Contains(t, iSync.Linked["moved.png"], "note.md")
+		assert.NotContains(t, iSync.Linked, "img.png")
+	})
+
+	t.Run("moved and deleted notes", func(t *testing.T) {
+		iSync, root := setup(t)
+		writeTestFiles(t, root, map[string]string{"note.md": "![](img.png) ![](other.png)"})
+		iSync.Sync(map[string]string{"img.png": "moved.png"})
+		assert.Equal(t, []string{"note.md"}, iSync.Conflicts())
+
+		writeTestFiles(t, root, map[string]string{"note2.md": "![](img.png) ![](other.png)"})
+		assert.NoError(t, os.Remove(filepath.Join(root, "note.md")))
+		iSync.MoveFile("note.md", "note2.md", nil)
+		assert.Equal(t, []string{"note2.md"}, iSync.Conflicts())
+
+		delete(iSync.Sources, "note2.md")
+		iSync.RetryConflicts()
+		assert.Empty(t, iSync.Conflicts())
+	})
+
+	t.Run("file written after reading", func(t *testing.T) {
+		iSync, root := setup(t)
+		stamp, err := fs.Stat(iSync.fileSystem, "note.md")
+		assert.NoError(t, err)
+		assert.NoError(t, iSync.checkStamp("note.md", stamp))
+		writeTestFiles(t, root, map[string]string{"note.md": "![](img.png) edited"})
+		assert.ErrorIs(t, iSync.checkStamp("note.md", stamp), ErrConflict)
+	})
+}
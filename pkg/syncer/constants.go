@@ -4,4 +4,16 @@ var ExcludedDirs = map[string]bool{"node_modules": true}
 
 var ParsableFilesExtension = ".md"
 
+// JournalDir is a default directory for the journal of rewrites relative to the root
+var JournalDir = ".linksyncer/journal"
+
+// IndexFile is a default path to the index of parsed files relative to the root
+var IndexFile = ".linksyncer/index.json"
+
+// IgnoreFiles are default names of the files with patterns of ignored paths
+var IgnoreFiles = []string{".gitignore", ".linksyncerignore"}
+
 var ImgExtensions = ".png|.jpg|.jpeg|.webp|.svg|.tiff|.tff|.gif"
+
+// AnyExtension in the list of linkable extensions allows to track files of any type
+const AnyExtension = "*"
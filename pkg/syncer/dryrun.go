@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"sort"
+
+	"github.com/flytaly/linksyncer/pkg/diff"
+)
+
+// FileDiff contains planned changes of a file in the unified diff format
+type FileDiff struct {
+	Path string
+	Diff string
+}
+
+type plannedChange struct {
+	before []byte
+	after  []byte
+}
+
+// readContent returns the file's content. In the dry-run mode it returns the content
+// with planned changes if the file should have been updated before.
+func (s *LinkSyncer) readContent(relativePath string) ([]byte, error) {
+	if s.DryRun {
+		if content, ok := s.planned[relativePath]; ok {
+			return content, nil
+		}
+	}
+	return s.ReadFile(relativePath)
+}
+
+// plan saves changes of the file instead of writing them in the dry-run mode
+func (s *LinkSyncer) plan(relativePath string, before, after []byte) {
+	s.planned[relativePath] = after
+	if change, ok := s.pending[relativePath]; ok {
+		change.after = after
+		return
+	}
+	s.pending[relativePath] = &plannedChange{before: before, after: after}
+}
+
+// movePlanned moves planned content of the file to its new path
+func (s *LinkSyncer) movePlanned(oldPath, newPath string) {
+	if content, ok := s.planned[oldPath]; ok {
+		s.planned[newPath] = content
+		delete(s.planned, oldPath)
+	}
+	if change, ok := s.pending[oldPath]; ok {
+		s.pending[newPath] = change
+		delete(s.pending, oldPath)
+	}
+}
+
+// TakeDiffs returns diffs of the changes planned in the dry-run mode
+// since the previous call. Diffs are sorted by file path.
+func (s *LinkSyncer) TakeDiffs() []FileDiff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []FileDiff{}
+	for path, change := range s.pending {
+		d := diff.Unified("a/"+path, "b/"+path, change.before, change.after)
+		if d != "" {
+			result = append(result, FileDiff{Path: path, Diff: d})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	s.pending = map[string]*plannedChange{}
+	return result
+}
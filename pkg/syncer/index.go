@@ -0,0 +1,163 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexVersion should be increased when the parser changes the way links are extracted
+const indexVersion = 4
+
+// files modified less than this interval before the index was saved are checked by their content,
+// because they might have been modified again within the file system's timestamp granularity
+const racyInterval = 2 * time.Second
+
+type fileIndex struct {
+	Version int                    `json:"version"`
+	Saved   time.Time              `json:"saved"`
+	Files   map[string]*indexEntry `json:"files"`
+}
+
+// indexEntry contains the file's stamp and links extracted from its content
+type indexEntry struct {
+	ModTime time.Time   `json:"mtime"`
+	Size    int64       `json:"size"`
+	Hash    string      `json:"hash"`
+	Links   []indexLink `json:"links,omitempty"`
+	Images  []indexLink `json:"images,omitempty"`
+}
+
+type indexLink struct {
+	Content string `json:"content"`
+	Dest    string `json:"dest"`
+	Wiki    bool   `json:"wiki,omitempty"`
+}
+
+func toIndexLinks(links []ContentLink) []indexLink {
+	result := []indexLink{}
+	for _, l := range links {
+		result = append(result, indexLink{Content: l.content, Dest: l.dest, Wiki: l.wiki})
+	}
+	return result
+}
+
+func fromIndexLinks(links []indexLink) []ContentLink {
+	result := []ContentLink{}
+	for _, l := range links {
+		result = append(result, ContentLink{content: l.Content, dest: l.Dest, wiki: l.Wiki})
+	}
+	return result
+}
+
+// indexPath returns absolute path to the index file
+func (s *LinkSyncer) indexPath() string {
+	if filepath.IsAbs(s.IndexPath) {
+		return s.IndexPath
+	}
+	return filepath.Join(s.root, s.IndexPath)
+}
+
+// loadIndex reads the saved index
+func (s *LinkSyncer) loadIndex() {
+	if s.IndexPath == "" || s.RebuildIndex {
+		return
+	}
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.log.Warning("Couldn't read index: %v", err)
+		}
+		return
+	}
+	index := fileIndex{}
+	if err = json.Unmarshal(data, &index); err != nil || index.Version != indexVersion || index.Files == nil {
+		s.log.Warning("Index %s is outdated or corrupted, all files will be parsed", s.IndexPath)
+		return
+	}
+	s.index = index.Files
+	s.indexSaved = index.Saved
+}
+
+// saveIndex writes entries of the current source files to the index file
+func (s *LinkSyncer) saveIndex() error {
+	if s.IndexPath == "" || s.ReadOnlyIndex || s.DryRun || len(s.Sources) == 0 { // files weren't processed
+		return nil
+	}
+	index := fileIndex{Version: indexVersion, Saved: time.Now(), Files: map[string]*indexEntry{}}
+	for path := range s.Sources {
+		if entry, ok := s.index[path]; ok {
+			index.Files[path] = entry
+		}
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	path := s.indexPath()
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// unchanged reports whether the file with the given info has the same content as when it was indexed
+func (e *indexEntry) unchanged(fi fs.FileInfo, saved time.Time) bool {
+	return e.Size == fi.Size() && e.ModTime.Equal(fi.ModTime()) && fi.ModTime().Before(saved.Add(-racyInterval))
+}
+
+// readEntry returns the index entry of the file. The file is parsed only if its content
+// differs from the old entry. It doesn't modify LinkSyncer, so it can be called concurrently.
+func (s *LinkSyncer) readEntry(relativePath string, old *indexEntry) (*indexEntry, error) {
+	fi, err := fs.Stat(s.fileSystem, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if old != nil && old.unchanged(fi, s.indexSaved) {
+		return old, nil
+	}
+
+	data, err := s.ReadFile(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	entry := &indexEntry{Hash: hashContent(data)}
+	if old != nil && old.Hash == entry.Hash {
+		entry.Links, entry.Images = old.Links, old.Images
+	} else {
+		links, images := contentLinks(string(data))
+		entry.Links, entry.Images = toIndexLinks(links), toIndexLinks(images)
+	}
+	entry.ModTime, entry.Size = fi.ModTime(), fi.Size()
+	return entry, nil
+}
+
+// updateIndex saves links of the written content in the index
+func (s *LinkSyncer) updateIndex(relativePath string, content []byte, links, images []ContentLink) {
+	entry := &indexEntry{
+		Size:   int64(len(content)),
+		Hash:   hashContent(content),
+		Links:  toIndexLinks(links),
+		Images: toIndexLinks(images),
+	}
+	if fi, err := fs.Stat(s.fileSystem, relativePath); err == nil {
+		entry.ModTime = fi.ModTime()
+	}
+	s.index[relativePath] = entry
+}
+
+// moveIndex moves the index entry of the moved file
+func (s *LinkSyncer) moveIndex(oldPath, newPath string) {
+	if entry, ok := s.index[oldPath]; ok {
+		s.index[newPath] = entry
+		delete(s.index, oldPath)
+	}
+}
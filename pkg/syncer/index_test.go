@@ -0,0 +1,99 @@
+package syncer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readCountingFS counts successful reads of the files
+type readCountingFS struct {
+	fs.FS
+	reads map[string]int
+}
+
+func (c *readCountingFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(c.FS, name)
+	if err == nil {
+		c.reads[name]++
+	}
+	return data, err
+}
+
+func (c *readCountingFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(c.FS, name)
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"index.md":      "![](img.png) [[note]]",
+		"notes/note.md": "[index](../index.md)",
+		"img.png":       "png",
+	})
+	past := time.Now().Add(-time.Hour)
+	setMtime := func(names ...string) {
+		for _, name := range names {
+			if err := os.Chtimes(filepath.Join(root, name), past, past); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	setMtime("index.md", "notes/note.md")
+
+	newSyncer := func(options ...func(*LinkSyncer)) (*LinkSyncer, *readCountingFS) {
+		fsys := &readCountingFS{FS: os.DirFS(root), reads: map[string]int{}}
+		options = append([]func(*LinkSyncer){func(s *LinkSyncer) { s.IndexPath = IndexFile }}, options...)
+		iSync := New(fsys, root, nil, options...)
+		iSync.ProcessFiles()
+		return iSync, fsys
+	}
+
+	first, fsys := newSyncer()
+	assert.Equal(t, map[string]int{"index.md": 1, "notes/note.md": 1}, fsys.reads)
+	assert.FileExists(t, filepath.Join(root, IndexFile))
+
+	t.Run("don't parse unchanged files", func(t *testing.T) {
+		iSync, fsys := newSyncer()
+		assert.Empty(t, fsys.reads)
+		assert.Equal(t, first.Sources, iSync.Sources)
+		assert.Equal(t, first.Linked, iSync.Linked)
+	})
+
+	t.Run("parse changed files", func(t *testing.T) {
+		writeTestFiles(t, root, map[string]string{"notes/note.md": "![](../img.png)"})
+		setMtime("notes/note.md")
+		writeTestFiles(t, root, map[string]string{"new.md": "[[index]]"})
+
+		iSync, fsys := newSyncer()
+		assert.Equal(t, map[string]int{"notes/note.md": 1, "new.md": 1}, fsys.reads)
+		assert.Equal(t, []LinkInfo{{rootPath: "img.png", path: "../img.png", fullLink: "[](../img.png)"}}, iSync.Sources["notes/note.md"])
+		assert.Contains(t, iSync.Linked, "index.md")
+	})
+
+	t.Run("rebuild index", func(t *testing.T) {
+		_, fsys := newSyncer(func(s *LinkSyncer) { s.RebuildIndex = true })
+		assert.Len(t, fsys.reads, 3)
+	})
+
+	t.Run("read-only index", func(t *testing.T) {
+		readOnly := func(s *LinkSyncer) { s.ReadOnlyIndex = true }
+		_, fsys := newSyncer(readOnly)
+		assert.NotContains(t, fsys.reads, "index.md", "the index should be loaded")
+
+		assert.NoError(t, os.Remove(filepath.Join(root, IndexFile)))
+		iSync, _ := newSyncer(readOnly)
+		iSync.Close()
+		assert.NoFileExists(t, filepath.Join(root, IndexFile))
+	})
+
+	t.Run("ignore corrupted index", func(t *testing.T) {
+		writeTestFiles(t, root, map[string]string{IndexFile: "{"})
+		_, fsys := newSyncer()
+		assert.Len(t, fsys.reads, 3)
+	})
+}
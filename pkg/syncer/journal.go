@@ -0,0 +1,219 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flytaly/linksyncer/pkg/log"
+)
+
+// Batch is a record of the files rewritten during a single synchronization
+type Batch struct {
+	ID    string            `json:"id"`
+	Time  time.Time         `json:"time"`
+	Moves map[string]string `json:"moves"` // moved files (from -> to)
+	Files []JournalFile     `json:"files"` // rewritten files
+}
+
+// JournalFile stores the original content of the rewritten file
+type JournalFile struct {
+	Path     string `json:"path"`     // path to the file after the synchronization
+	Original []byte `json:"original"` // content before rewriting
+	Hash     string `json:"hash"`     // hash of the written content
+}
+
+var ErrNoBatches = errors.New("journal is empty")
+
+const batchExt = ".json"
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// journalPath returns absolute path to the journal directory
+func (s *LinkSyncer) journalPath() string {
+	if filepath.IsAbs(s.JournalDir) {
+		return s.JournalDir
+	}
+	return filepath.Join(s.root, s.JournalDir)
+}
+
+// beginBatch starts recording rewritten files
+func (s *LinkSyncer) beginBatch(moves map[string]string) {
+	if s.JournalDir == "" || s.DryRun {
+		return
+	}
+	now := time.Now()
+	s.batch = &Batch{
+		ID:    now.Format("20060102T150405.000000"),
+		Time:  now,
+		Moves: map[string]string{},
+	}
+	for from, to := range moves {
+		s.batch.Moves[from] = to
+	}
+}
+
+// logger returns the logger that adds the id of the current batch to the records
+func (s *LinkSyncer) logger() log.Logger {
+	if s.batch == nil {
+		return s.log
+	}
+	return s.log.With("batch", s.batch.ID)
+}
+
+// recordWrite saves the original content of the rewritten file in the current batch
+func (s *LinkSyncer) recordWrite(relativePath string, original, written []byte) {
+	if s.batch == nil {
+		return
+	}
+	for i, f := range s.batch.Files {
+		if f.Path == relativePath { // file was rewritten again, keep the first version
+			s.batch.Files[i].Hash = hashContent(written)
+			return
+		}
+	}
+	s.batch.Files = append(s.batch.Files, JournalFile{
+		Path:     relativePath,
+		Original: original,
+		Hash:     hashContent(written),
+	})
+}
+
+// commitBatch saves the current batch in the journal if any files were rewritten
+func (s *LinkSyncer) commitBatch() {
+	batch := s.batch
+	s.batch = nil
+	if batch == nil || len(batch.Files) == 0 {
+		return
+	}
+	err := s.saveBatch(batch)
+	if err != nil {
+		s.log.With("batch", batch.ID).Error("Couldn't save batch %s to the journal: %v", batch.ID, err)
+		return
+	}
+	s.log.With("batch", batch.ID).Info("Batch %s saved to the journal", batch.ID)
+}
+
+func (s *LinkSyncer) saveBatch(batch *Batch) error {
+	dir := s.journalPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, batch.ID+batchExt), data, 0644)
+}
+
+func (s *LinkSyncer) loadBatch(id string) (*Batch, error) {
+	data, err := os.ReadFile(filepath.Join(s.journalPath(), id+batchExt))
+	if err != nil {
+		return nil, err
+	}
+	batch := &Batch{}
+	if err = json.Unmarshal(data, batch); err != nil {
+		return nil, fmt.Errorf("batch %s is corrupted: %w", id, err)
+	}
+	return batch, nil
+}
+
+// BatchIDs returns IDs of the batches in the journal from the oldest to the newest
+func (s *LinkSyncer) BatchIDs() ([]string, error) {
+	entries, err := os.ReadDir(s.journalPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), batchExt) {
+			ids = append(ids, strings.TrimSuffix(e.Name(), batchExt))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Batch returns batch with the given ID, or the last batch if id is empty
+func (s *LinkSyncer) Batch(id string) (*Batch, error) {
+	if id == "" {
+		ids, err := s.BatchIDs()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, ErrNoBatches
+		}
+		id = ids[len(ids)-1]
+	}
+	return s.loadBatch(id)
+}
+
+// Undo restores the files rewritten in the given batch (or in the last one if id is empty)
+// and moves the moved files back. It fails if any of the rewritten files have been edited since.
+// The cache isn't updated, so files should be processed again.
+// In dry-run mode nothing is reverted, the returned batch shows what would be.
+func (s *LinkSyncer) Undo(id string) (*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, err := s.Batch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	modified := []string{}
+	for _, f := range batch.Files {
+		data, err := os.ReadFile(filepath.Join(s.root, f.Path))
+		if err != nil || hashContent(data) != f.Hash {
+			modified = append(modified, f.Path)
+		}
+	}
+	if len(modified) > 0 {
+		return nil, fmt.Errorf("files have been modified since batch %s: %s", batch.ID, strings.Join(modified, ", "))
+	}
+	if s.DryRun {
+		return batch, nil
+	}
+
+	for _, f := range batch.Files {
+		if err = writeFile(filepath.Join(s.root, f.Path), f.Original); err != nil {
+			return nil, err
+		}
+		s.log.With("batch", batch.ID, "source", f.Path).Info("File restored: %s", f.Path)
+	}
+
+	for from, to := range batch.Moves {
+		oldPath, newPath := filepath.Join(s.root, from), filepath.Join(s.root, to)
+		if _, err := os.Stat(oldPath); err == nil { // don't overwrite existing files
+			s.log.With("batch", batch.ID, "from", to, "to", from).Warning("Couldn't move %s back: %s already exists", to, from)
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(oldPath), 0755); err == nil {
+			err = os.Rename(newPath, oldPath)
+		}
+		if err != nil {
+			s.log.With("batch", batch.ID, "from", to, "to", from).Error("Couldn't move %s back to %s: %v", to, from, err)
+			continue
+		}
+		s.log.With("batch", batch.ID, "from", to, "to", from).Info("File moved back: %s -> %s", to, from)
+	}
+
+	if err = os.Remove(filepath.Join(s.journalPath(), batch.ID+batchExt)); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
@@ -0,0 +1,124 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJournal(t *testing.T) {
+	setup := func(t *testing.T) (*LinkSyncer, string) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{
+			"index.md":       "![](assets/img.png)",
+			"notes/note.md":  "![](../assets/img.png)",
+			"assets/img.png": "png",
+		})
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.JournalDir = JournalDir
+		})
+		iSync.ProcessFiles()
+
+		// move image and sync
+		err := os.Rename(filepath.Join(root, "assets/img.png"), filepath.Join(root, "img.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		iSync.Sync(map[string]string{"assets/img.png": "img.png"})
+		assert.Equal(t, "![](img.png)", readTestFile(t, filepath.Join(root, "index.md")))
+		return iSync, root
+	}
+
+	t.Run("save batch", func(t *testing.T) {
+		iSync, _ := setup(t)
+
+		ids, err := iSync.BatchIDs()
+		assert.NoError(t, err)
+		assert.Len(t, ids, 1)
+
+		batch, err := iSync.Batch("")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"assets/img.png": "img.png"}, batch.Moves)
+		assert.ElementsMatch(t, []JournalFile{
+			{Path: "index.md", Original: []byte("![](assets/img.png)"), Hash: hashContent([]byte("![](img.png)"))},
+			{Path: "notes/note.md", Original: []byte("![](../assets/img.png)"), Hash: hashContent([]byte("![](../img.png)"))},
+		}, batch.Files)
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		iSync, root := setup(t)
+
+		_, err := iSync.Undo("")
+		assert.NoError(t, err)
+		assert.Equal(t, "![](assets/img.png)", readTestFile(t, filepath.Join(root, "index.md")))
+		assert.Equal(t, "![](../assets/img.png)", readTestFile(t, filepath.Join(root, "notes/note.md")))
+		assert.Equal(t, "png", readTestFile(t, filepath.Join(root, "assets/img.png")), "should move file back")
+
+		_, err = iSync.Undo("")
+		assert.ErrorIs(t, err, ErrNoBatches, "batch should be removed from the journal")
+	})
+
+	t.Run("undo in dry-run mode", func(t *testing.T) {
+		iSync, root := setup(t)
+		iSync.DryRun = true
+
+		batch, err := iSync.Undo("")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"assets/img.png": "img.png"}, batch.Moves)
+		assert.Len(t, batch.Files, 2)
+		assert.Equal(t, "![](img.png)", readTestFile(t, filepath.Join(root, "index.md")))
+		assert.Equal(t, "png", readTestFile(t, filepath.Join(root, "img.png")), "file shouldn't be moved back")
+		ids, err := iSync.BatchIDs()
+		assert.NoError(t, err)
+		assert.Len(t, ids, 1, "batch should be kept in the journal")
+	})
+
+	t.Run("don't undo modified files", func(t *testing.T) {
+		iSync, root := setup(t)
+		writeTestFiles(t, root, map[string]string{"notes/note.md": "edited"})
+
+		_, err := iSync.Undo("")
+		assert.Error(t, err)
+		assert.Equal(t, "![](img.png)", readTestFile(t, filepath.Join(root, "index.md")))
+		assert.Equal(t, "edited", readTestFile(t, filepath.Join(root, "notes/note.md")))
+	})
+
+	t.Run("disabled in dry-run mode", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{"index.md": "![](img.png)", "img.png": ""})
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.JournalDir = JournalDir
+			s.DryRun = true
+		})
+		iSync.ProcessFiles()
+		iSync.Sync(map[string]string{"img.png": "img2.png"})
+
+		ids, err := iSync.BatchIDs()
+		assert.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/flytaly/linksyncer/pkg/fswatcher"
+	"github.com/flytaly/linksyncer/pkg/ignore"
 	"github.com/flytaly/linksyncer/pkg/log"
 )
 
@@ -23,6 +26,59 @@ type LinkSyncer struct {
 	Linked      map[string]map[string]Empty // map linked file paths to their source files
 	MaxFileSize int64                       // max file size in bytes for parsable files
 
+	// LinkableExtensions is a list of extensions of the files that are tracked
+	// besides parsable files, so links to them are updated when they are moved.
+	// AnyExtension allows to track files of any type.
+	LinkableExtensions []string
+	linkable           *regexp.Regexp // nil if any file is linkable
+
+	// ParsableExtensions is a list of extensions of the notes that are parsed as Markdown
+	ParsableExtensions []string
+	parsable           *regexp.Regexp // nil if any file is parsable
+
+	// ExcludedDirs contains names of the directories that aren't watched.
+	// Hidden directories are always skipped.
+	ExcludedDirs map[string]bool
+	// IgnoreFiles are names of gitignore-style files in the root and nested directories.
+	// Paths matched by them are neither watched nor rewritten.
+	IgnoreFiles []string
+	ignore      *ignore.Matcher
+
+	// DryRun prevents writing files. Planned changes can be retrieved with TakeDiffs.
+	DryRun  bool
+	planned map[string][]byte         // content of the files with planned changes
+	pending map[string]*plannedChange // changes that weren't taken yet
+
+	// JournalDir is a directory where original content of the rewritten files is saved,
+	// so synchronizations can be undone. The journal is disabled if it's empty.
+	JournalDir string
+	batch      *Batch                 // batch of the current synchronization
+	conflicts  map[string][]MovedLink // notes that weren't updated because they were modified
+
+	// IndexPath is a file where links extracted from the notes are saved, so only changed files
+	// are parsed on the next start. The index isn't saved if it's empty.
+	IndexPath string
+	// RebuildIndex ignores the saved index and parses all files
+	RebuildIndex bool
+	// ReadOnlyIndex loads the saved index but never writes it, for commands that don't modify the notes
+	ReadOnlyIndex bool
+	index         map[string]*indexEntry // indexed source files
+	indexSaved    time.Time              // time when the loaded index was saved
+
+	// LinkStyle is a style of the rewritten link paths: LinkStyleKeep, LinkStyleRelative, LinkStyleRoot or LinkStyleAbsolute
+	LinkStyle string
+
+	// Backups is a number of copies kept for every rewritten note, 0 disables backups
+	Backups int
+	// BackupDir is a directory for the copies, relative to the watched directory.
+	// If it's empty, only the last copy is kept next to the note with the ".bak" extension.
+	BackupDir string
+
+	// Workers is a number of files that are read and parsed concurrently by ProcessFiles
+	Workers int
+
+	names map[string]map[string]Empty // map lowercased file names to paths of the watched files
+
 	Watcher fswatcher.FsWatcher
 
 	stopEvents chan Empty
@@ -30,29 +86,79 @@ type LinkSyncer struct {
 	mu         *sync.Mutex
 }
 
-// var watchedExt = regexp.MustCompile("(?i)(" + ImgExtensions + "|" + ParsableFilesExtension + ")$")
-var parsableFiles = regexp.MustCompile("(?i)(" + ParsableFilesExtension + ")$")
-var imageFiles = regexp.MustCompile("(?i)(" + ImgExtensions + ")$")
+var imageFiles = extensionsRegexp(strings.Split(ImgExtensions, "|"))
+
+// extensionsRegexp returns regexp that matches files with given extensions,
+// or nil if any extension is allowed
+func extensionsRegexp(extensions []string) *regexp.Regexp {
+	quoted := []string{}
+	for _, ext := range extensions {
+		ext = strings.TrimSpace(ext)
+		if ext == AnyExtension {
+			return nil
+		}
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		quoted = append(quoted, regexp.QuoteMeta(ext))
+	}
+	if len(quoted) == 0 {
+		return regexp.MustCompile(`[^\s\S]`) // doesn't match anything
+	}
+	return regexp.MustCompile("(?i)(" + strings.Join(quoted, "|") + ")$")
+}
 
 const MaxFileSize int64 = 1024 * 1024
 
-func getShouldSkipPath(iSync *LinkSyncer) func(fs.FileInfo) bool {
-	return func(fi fs.FileInfo) bool {
-		name := fi.Name()
-		if fi.IsDir() {
-			if name == "." { // don't skip root folder
-				return false
-			}
-			// TODO: should be optional
-			return strings.HasPrefix(name, ".") || ExcludedDirs[name]
-		}
+// filters returns filters of the watched paths in the order of evaluation
+func (s *LinkSyncer) filters() []fswatcher.Filter {
+	return []fswatcher.Filter{s.skipDirs, s.ignored, s.skipFiles}
+}
 
-		if parsableFiles.MatchString(name) {
-			return fi.Size() > iSync.MaxFileSize
-		}
+// skipDirs skips hidden and excluded directories
+func (s *LinkSyncer) skipDirs(path string, d fs.DirEntry) fswatcher.Decision {
+	name := d.Name()
+	if !d.IsDir() || name == "." || path == "." { // don't skip root folder
+		return fswatcher.Undecided
+	}
+	// TODO: should be optional
+	if strings.HasPrefix(name, ".") || s.ExcludedDirs[name] {
+		return fswatcher.Skip
+	}
+	return fswatcher.Undecided
+}
+
+// ignored skips paths matched by ignore files. Ignore files themselves are watched
+// to notice changes of the rules.
+func (s *LinkSyncer) ignored(path string, d fs.DirEntry) fswatcher.Decision {
+	if !d.IsDir() && s.ignore.IsIgnoreFile(path) {
+		return fswatcher.Include
+	}
+	if path != "." && s.ignore.Ignored(path, d.IsDir()) {
+		return fswatcher.Skip
+	}
+	return fswatcher.Undecided
+}
 
-		return !imageFiles.MatchString(name)
+// skipFiles skips big notes and files that are neither parsable nor linkable
+func (s *LinkSyncer) skipFiles(path string, d fs.DirEntry) fswatcher.Decision {
+	if d.IsDir() {
+		return fswatcher.Undecided
+	}
+	if s.isParsable(d.Name()) {
+		fi, err := d.Info()
+		if err != nil || fi.Size() > s.MaxFileSize {
+			return fswatcher.Skip
+		}
+		return fswatcher.Undecided
+	}
+	if !s.isLinkable(d.Name()) {
+		return fswatcher.Skip
 	}
+	return fswatcher.Undecided
 }
 
 // Creates a new LinkSyncer
@@ -66,30 +172,50 @@ func New(fileSystem fs.FS, root string, logger log.Logger, options ...func(*Link
 		Watcher:     watcher,
 		Sources:     map[string][]LinkInfo{},
 		Linked:      map[string]map[string]Empty{},
+		names:       map[string]map[string]Empty{},
+		index:       map[string]*indexEntry{},
+		planned:     map[string][]byte{},
+		pending:     map[string]*plannedChange{},
+		conflicts:   map[string][]MovedLink{},
 		fileSystem:  fileSystem,
 		stopEvents:  make(chan Empty),
 		mu:          new(sync.Mutex),
 		log:         logger,
 		MaxFileSize: MaxFileSize,
+		Workers:     runtime.NumCPU(),
+
+		LinkableExtensions: strings.Split(ImgExtensions, "|"),
+		ParsableExtensions: []string{ParsableFilesExtension},
+		ExcludedDirs:       map[string]bool{},
+		IgnoreFiles:        IgnoreFiles,
+		LinkStyle:          LinkStyleKeep,
+	}
+	for dir := range ExcludedDirs {
+		iSync.ExcludedDirs[dir] = true
 	}
 
 	for _, option := range options {
 		option(iSync)
 	}
 
-	watcher.AddShouldSkipHook(getShouldSkipPath(iSync))
+	iSync.linkable = extensionsRegexp(iSync.LinkableExtensions)
+	iSync.parsable = extensionsRegexp(iSync.ParsableExtensions)
+	iSync.ignore = ignore.New(fileSystem, iSync.IgnoreFiles...)
+
+	for _, filter := range iSync.filters() {
+		iSync.Watcher.AddFilter(filter)
+	}
 
 	return iSync
 }
 
-var extractLinks = GetLinksFromFile
 var writeFile = func(absPath string, data []byte) error {
 	info, err := os.Stat(absPath)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(absPath, data, info.Mode())
+	return atomicWriteFile(absPath, data, info.Mode())
 }
 
 func (s *LinkSyncer) processDirs(dirs []string) {
@@ -99,47 +225,148 @@ func (s *LinkSyncer) processDirs(dirs []string) {
 			s.log.Error("Couldn't add folder %s to watcher: %v", current, err)
 			continue
 		}
+		for f, fi := range paths {
+			if !(*fi).IsDir() {
+				s.addName(f)
+			}
+		}
+		files := []string{}
 		for f, fi := range paths {
 			if !(*fi).IsDir() && s.isParsable(f) {
-				s.AddFile(f)
+				files = append(files, f)
+			}
+		}
+		s.addFiles(files)
+	}
+}
+
+// addFiles reads and parses files using a pool of workers and saves their links
+func (s *LinkSyncer) addFiles(files []string) {
+	if s.Workers <= 1 || len(files) < 2 {
+		for _, f := range files {
+			s.mu.Lock()
+			s.AddFile(f)
+			s.mu.Unlock()
+		}
+		return
+	}
+
+	type result struct {
+		path  string
+		entry *indexEntry
+		err   error
+	}
+	// take old entries beforehand, because the index is modified while merging results
+	old := make([]*indexEntry, len(files))
+	for i, f := range files {
+		old[i] = s.index[f]
+	}
+
+	jobs := make(chan int)
+	results := make(chan result)
+	wg := sync.WaitGroup{}
+	for w := 0; w < min(s.Workers, len(files)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry, err := s.readEntry(files[i], old[i])
+				results <- result{files[i], entry, err}
 			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			jobs <- i
 		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		s.mu.Lock()
+		s.addEntry(r.path, r.entry, r.err)
+		s.mu.Unlock()
 	}
 }
 
 func (s *LinkSyncer) isParsable(f string) bool {
-	return parsableFiles.MatchString(f)
+	return s.parsable == nil || s.parsable.MatchString(f)
+}
+
+func (s *LinkSyncer) isLinkable(f string) bool {
+	return s.linkable == nil || s.linkable.MatchString(f)
+}
+
+// Tracked reports whether the file is a note or is linked from a note,
+// so its renames have to be detected to update the links
+func (s *LinkSyncer) Tracked(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Sources[path]; ok {
+		return true
+	}
+	_, ok := s.Linked[path]
+	return ok
 }
 
-// ProcessFiles walks the file tree and adds valid files
+// ProcessFiles walks the file tree and adds valid files.
+// Files that haven't changed since they were saved in the index aren't parsed.
 func (s *LinkSyncer) ProcessFiles() time.Duration {
 	t := time.Now()
+	if len(s.index) == 0 {
+		s.loadIndex()
+	}
 	s.processDirs([]string{s.root})
+	if err := s.saveIndex(); err != nil {
+		s.log.Error("Couldn't save index: %v", err)
+	}
 	return time.Since(t)
 }
 
 // AddFile reads, parses and saves info about given file and its links
 func (s *LinkSyncer) AddFile(relativePath string) {
+	entry, err := s.readEntry(relativePath, s.index[relativePath])
+	s.addEntry(relativePath, entry, err)
+}
+
+// addEntry saves links of the indexed file
+func (s *LinkSyncer) addEntry(relativePath string, entry *indexEntry, err error) {
 	s.Sources[relativePath] = []LinkInfo{}
-	data, err := s.ReadFile(relativePath)
 	if err != nil {
 		s.log.Error("Couldn't read file. %s", err)
 		return
 	}
 
-	links, images := extractLinks(relativePath, string(data))
+	s.index[relativePath] = entry
+	links, images := s.getLinks(relativePath, fromIndexLinks(entry.Links), fromIndexLinks(entry.Images))
 	s.saveLinks(relativePath, links, images)
 }
 
+// getLinks converts extracted links to LinkInfo and resolves wiki links
+func (s *LinkSyncer) getLinks(relativePath string, linkList, imgList []ContentLink) (links []LinkInfo, images []LinkInfo) {
+	links = processLinks(relativePath, linkList)
+	images = processLinks(relativePath, imgList)
+	s.resolveWikiLinks(relativePath, links)
+	s.resolveWikiLinks(relativePath, images)
+	s.resolveRootLinks(links)
+	s.resolveRootLinks(images)
+	return links, images
+}
+
 func (s *LinkSyncer) AddPath(path string) {
 	fi, err := fs.Stat(s.fileSystem, path)
 	if err != nil {
 		s.log.Error("Couldn't get FileInfo. %s", err)
 		return
 	}
+	if !fi.IsDir() {
+		s.addName(path)
+	}
 	if !fi.IsDir() && s.isParsable(path) {
 		s.AddFile(path)
-		s.log.Info("Added file: %s", path)
+		s.log.With("source", path).Info("Added file: %s", path)
 	}
 }
 
@@ -173,15 +400,17 @@ func (s *LinkSyncer) RemoveFile(relativePath string) {
 		}
 		delete(s.Sources, relativePath)
 	}
+	delete(s.index, relativePath)
 }
 
 func (s *LinkSyncer) UpdateFile(relativePath string) {
+	delete(s.planned, relativePath) // the file was changed, so planned changes are outdated
 	if linked, ok := s.Sources[relativePath]; ok {
 		for _, li := range linked {
 			s.clearLinkReferences(relativePath, li.rootPath)
 		}
 		s.AddFile(relativePath)
-		s.log.Info("File updated: %s", relativePath)
+		s.log.With("source", relativePath).Info("File updated: %s", relativePath)
 		return
 	}
 	s.AddPath(relativePath)
@@ -198,6 +427,11 @@ func (s *LinkSyncer) MoveFile(oldPath, newPath string, moves map[string]string)
 	}
 	s.Sources[newPath] = s.Sources[oldPath]
 	delete(s.Sources, oldPath)
+	s.moveIndex(oldPath, newPath)
+	s.moveConflict(oldPath, newPath)
+	if s.DryRun {
+		s.movePlanned(oldPath, newPath)
+	}
 	if len(links) == 0 {
 		return
 	}
@@ -215,34 +449,65 @@ func (s *LinkSyncer) MoveFile(oldPath, newPath string, moves map[string]string)
 		movedLinks = append(movedLinks, ml)
 		s.clearLinkReferences(oldPath, link.rootPath)
 	}
-	s.log.Info("File moved: %s -> %s", oldPath, newPath)
-	err := s.UpdateLinksInFile(newPath, movedLinks)
-	if err != nil {
-		s.log.Error("Couldn't update links in %s. Error: %v", newPath, err)
-		return
-	}
+	s.logger().With("from", oldPath, "to", newPath).Info("File moved: %s -> %s", oldPath, newPath)
+	s.updateLinks(newPath, movedLinks)
 }
 
-// UpdateLinksInFile replaces links in the file
+// UpdateLinksInFile replaces links in the file.
+// In the dry-run mode the file isn't written, but the cache is updated as if it was.
+// ErrConflict is returned if the file was modified since it was indexed or while it was rewritten.
 func (s *LinkSyncer) UpdateLinksInFile(relativePath string, movedLinks []MovedLink) error {
-	content, err := s.ReadFile(relativePath)
+	var stamp fs.FileInfo
+	var err error
+	if !s.DryRun {
+		// the stamp is taken before reading, so changes made right after the read are noticed
+		if stamp, err = fs.Stat(s.fileSystem, relativePath); err != nil {
+			return err
+		}
+	}
+	content, err := s.readContent(relativePath)
 	if err != nil {
 		return err
 	}
+	if !s.DryRun {
+		if err = s.checkUnchanged(relativePath, content); err != nil {
+			return err
+		}
+	}
 
-	updated := ReplaceLinks(relativePath, content, movedLinks)
+	updated := s.replaceLinks(relativePath, content, movedLinks)
 
-	err = writeFile(filepath.Join(s.root, relativePath), updated)
-	if err != nil {
-		return err
+	if s.DryRun {
+		s.plan(relativePath, content, updated)
+	} else {
+		if err = s.backup(relativePath, content); err != nil {
+			return fmt.Errorf("couldn't back up %s: %w", relativePath, err)
+		}
+		if err = s.checkStamp(relativePath, stamp); err != nil {
+			return err
+		}
+		err = writeFile(filepath.Join(s.root, relativePath), updated)
+		if err != nil {
+			return err
+		}
+		s.recordWrite(relativePath, content, updated)
 	}
 
-	links, images := extractLinks(relativePath, string(updated))
+	linkList, imgList := contentLinks(string(updated))
+	if !s.DryRun {
+		s.updateIndex(relativePath, updated, linkList, imgList)
+	}
+	links, images := s.getLinks(relativePath, linkList, imgList)
 	for _, link := range movedLinks {
 		s.clearLinkReferences(relativePath, link.link.rootPath)
 	}
 	s.saveLinks(relativePath, links, images)
-	s.log.Info("Links updated: %s", relativePath)
+	logger := s.logger().With("source", relativePath)
+	if s.DryRun {
+		logger.Info("Links would be updated: %s", relativePath)
+		return nil
+	}
+	logger.Info("Links updated: %s", relativePath)
 
 	return nil
 }
@@ -252,6 +517,9 @@ func (s *LinkSyncer) UpdateLinksInFile(relativePath string, movedLinks []MovedLi
 func (s *LinkSyncer) Sync(moves map[string]string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.beginBatch(moves)
+	defer s.commitBatch()
+	moves = s.expandMoves(moves)
 	// 1) At first, update the files that were moved and collect moved linked files
 	movedLinks := map[string]string{}
 	for from, to := range moves {
@@ -260,17 +528,57 @@ func (s *LinkSyncer) Sync(moves map[string]string) {
 		}
 		if s.Linked[from] != nil { // if linked file was moved store it in the map
 			movedLinks[from] = to
-			s.log.Info("Linked file moved: %s -> %s", from, to)
+			s.logger().With("from", from, "to", to).Info("Linked file moved: %s -> %s", from, to)
 		}
 	}
 	// 2) Then synchronize rest of the files that depends on moved linked files
 	fileMap := s.getFilesToSync(movedLinks)
 	for sourceFile, links := range fileMap {
-		err := s.UpdateLinksInFile(sourceFile, links)
-		if err != nil {
-			s.log.Error("Couldn't update links in %s. Error: %v", sourceFile, err)
+		s.updateLinks(sourceFile, links)
+	}
+}
+
+// expandMoves returns moves of the files inside the moved directories along with the given moves.
+// Paths are moved by replacing the directory prefix, so the content of the directory
+// doesn't have to be matched file by file.
+func (s *LinkSyncer) expandMoves(moves map[string]string) map[string]string {
+	expanded := make(map[string]string, len(moves))
+	dirs := map[string]string{}
+	for from, to := range moves {
+		expanded[from] = to
+		if fi, err := fs.Stat(s.fileSystem, to); err == nil && fi.IsDir() {
+			dirs[from] = to
+		}
+	}
+	if len(dirs) == 0 {
+		return expanded
+	}
+	add := func(path string) {
+		if _, ok := expanded[path]; ok {
+			return
+		}
+		for from, to := range dirs {
+			if newPath, ok := movedPrefix(path, from, to); ok {
+				expanded[path] = newPath
+				return
+			}
 		}
 	}
+	for path := range s.Sources {
+		add(path)
+	}
+	for path := range s.Linked {
+		add(path)
+	}
+	return expanded
+}
+
+// movedPrefix returns the new path of the file if it's inside the moved directory
+func movedPrefix(path, from, to string) (string, bool) {
+	if !strings.HasPrefix(path, from+"/") {
+		return "", false
+	}
+	return to + strings.TrimPrefix(path, from), true
 }
 
 // getFilesToSync collects notes that should be updated due to linked files relocation
@@ -322,14 +630,27 @@ func (s *LinkSyncer) ReadFile(filePath string) ([]byte, error) {
 func (s *LinkSyncer) processEvent(event fswatcher.Event, moves *map[string]string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	// rules are read again, the watcher applies them on the next scan
+	for _, name := range []string{event.Name, event.NewPath} {
+		if name != "" && s.ignore.IsIgnoreFile(name) {
+			s.ignore.Reset(path.Dir(name))
+			s.Watcher.Rescan()
+		}
+	}
 	switch event.Op {
 	case fswatcher.Create:
 		s.AddPath(event.Name)
 	case fswatcher.Remove:
+		s.removeName(event.Name)
 		s.RemoveFile(event.Name)
 	case fswatcher.Write:
 		s.UpdateFile(event.Name)
 	case fswatcher.Rename:
+		if event.IsDir {
+			s.renameDir(event.Name, event.NewPath)
+		} else {
+			s.renameName(event.Name, event.NewPath)
+		}
 		(*moves)[event.Name] = event.NewPath
 	}
 }
@@ -394,7 +715,13 @@ func (s *LinkSyncer) Watch(interval time.Duration) {
 }
 
 func (s *LinkSyncer) Close() {
-	err := s.Watcher.Close()
+	s.mu.Lock()
+	err := s.saveIndex()
+	s.mu.Unlock()
+	if err != nil {
+		s.log.Error("Couldn't save index: %v", err)
+	}
+	err = s.Watcher.Close()
 	if err != nil {
 		fmt.Println("Couldn't close watcher")
 	}
@@ -1,7 +1,9 @@
 package syncer
 
 import (
+	"fmt"
 	"io/fs"
+	"os"
 	"testing"
 	"testing/fstest"
 	"time"
@@ -48,6 +50,16 @@ func TestProcessFiles(t *testing.T) {
 		assert.Equal(t, iSync.SourcesNum(), len(wantFiles))
 		assert.Equal(t, iSync.RefsNum(), len(wantRefs))
 	})
+
+	for _, workers := range []int{1, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			mapFS, wantFiles, wantRefs := GetTestFileSys()
+			iSync := New(mapFS, "notes", nil, func(s *LinkSyncer) { s.Workers = workers })
+			_ = iSync.ProcessFiles()
+			assert.Equal(t, wantFiles, iSync.Sources)
+			assert.Equal(t, wantRefs, iSync.Linked)
+		})
+	}
 }
 
 func TestRemoveFile(t *testing.T) {
@@ -129,7 +141,7 @@ func TestMoveFile(t *testing.T) {
 	assert.Contains(t, iSync.Linked[linkedFile2], to, "should add new reference")
 
 	expected := map[string]string{
-		to: `![alt text](folder/assets/image01.png)\n![alt text](folder/assets/image02.png)`,
+		to: `![alt text](./folder/assets/image01.png)\n![alt text](./folder/assets/image02.png)`,
 	}
 	assert.Equal(t, expected, *gotData)
 }
@@ -182,7 +194,7 @@ func TestUpdateImageLinks(t *testing.T) {
 		t.Cleanup(func() { restore() })
 
 		_ = iSync.UpdateLinksInFile(note, imgs)
-		want := map[string]string{note: `![alt text](img/картинка.png)`}
+		want := map[string]string{note: `![alt text](./img/%D0%BA%D0%B0%D1%80%D1%82%D0%B8%D0%BD%D0%BA%D0%B0.png)`} // the original is encoded
 		assert.Equal(t, want, *written, "image links in the file should be updated")
 	})
 }
@@ -204,7 +216,7 @@ func TestSync(t *testing.T) {
 			{
 				from: "notes/folder/note2.md",
 				to:   "notes/folder/note3.md",
-				body: "![alt text](assets/image02.png)",
+				body: "![alt text](./assets/image02.png)",
 			},
 		}
 		links := map[string]string{
@@ -252,13 +264,13 @@ func TestSync(t *testing.T) {
 			if assert.Contains(t, iSync.Sources, staticNote) {
 				assert.Equal(t, []LinkInfo{{
 					rootPath: "notes/index_assets/index.png",
-					path:     "index_assets/index.png",
-					fullLink: "[alt text](index_assets/index.png)",
+					path:     "./index_assets/index.png",
+					fullLink: "[alt text](./index_assets/index.png)",
 				}}, iSync.Sources[staticNote])
 			}
 
 			if assert.Contains(t, *written, staticNote, "should write updated body") {
-				assert.Equalf(t, "![alt text](index_assets/index.png)", (*written)[staticNote], "should update links in the %s's", staticNote)
+				assert.Equalf(t, "![alt text](./index_assets/index.png)", (*written)[staticNote], "should update links in the %s's", staticNote)
 			}
 		})
 
@@ -283,6 +295,42 @@ func TestSync(t *testing.T) {
 		expect := "![](rnd/img1.png)\n!Some Text\n![](rnd/img1.png)"
 		assert.Equal(t, expect, (*gotData)[to])
 	})
+
+	t.Run("directory", func(t *testing.T) {
+		var fs fstest.MapFS = make(map[string]*fstest.MapFile)
+		fs["notes/index.md"] = &fstest.MapFile{Data: []byte("[a](projects/a.md)\n![](projects/sub/img.png)\n[[b]]")}
+		fs["notes/projects/a.md"] = &fstest.MapFile{Data: []byte("![](sub/img.png)\n![](../index.png)")}
+		fs["notes/projects/sub/b.md"] = &fstest.MapFile{Data: []byte("[index](../../index.md)")}
+		fs["notes/projects/sub/img.png"] = &fstest.MapFile{}
+		fs["notes/index.png"] = &fstest.MapFile{}
+		iSync := NewTestISync(fs, ".")
+		iSync.ProcessFiles()
+
+		gotData, restore := mockWriteFile(t)
+		t.Cleanup(func() { restore() })
+
+		from, to := "notes/projects", "notes/archive/projects"
+		for path, file := range fs {
+			if newPath, ok := movedPrefix(path, from, to); ok {
+				fs[newPath] = file
+				delete(fs, path)
+			}
+		}
+		iSync.mu.Lock()
+		iSync.renameDir(from, to)
+		iSync.mu.Unlock()
+		iSync.Sync(map[string]string{from: to})
+
+		assert.Equal(t, map[string]string{
+			"notes/index.md":                  "[a](archive/projects/a.md)\n![](archive/projects/sub/img.png)\n[[b]]",
+			"notes/archive/projects/a.md":     "![](sub/img.png)\n![](../../index.png)",
+			"notes/archive/projects/sub/b.md": "[index](../../../index.md)",
+		}, *gotData)
+		assert.NotContains(t, iSync.Sources, "notes/projects/a.md")
+		assert.Contains(t, iSync.Sources, "notes/archive/projects/sub/b.md")
+		assert.Contains(t, iSync.Linked, "notes/archive/projects/sub/img.png")
+		assert.NotContains(t, iSync.Linked, "notes/projects/sub/img.png")
+	})
 }
 
 func TestWatch(t *testing.T) {
@@ -344,7 +392,7 @@ func TestWatch(t *testing.T) {
 		time.Sleep(time.Millisecond * 40)
 		iSync.Close()
 
-		expected := map[string]string{noteTo: "![](assets/image1.png)"}
+		expected := map[string]string{noteTo: "![](./assets/image1.png)"}
 		assert.Equal(t, expected, *gotData)
 	})
 }
@@ -369,13 +417,8 @@ func TestSkipFiles(t *testing.T) {
 		iSync := New(fs, ".", nil, func(s *LinkSyncer) {
 			s.MaxFileSize = 2 * 1024
 		})
-		shouldSkipFn := getShouldSkipPath(iSync)
 		isSkipped := func(name string) bool {
-			info, err := fs.Stat(name)
-			if err != nil {
-				t.Fatal(err)
-			}
-			return shouldSkipFn(info)
+			return skipped(t, iSync, fs, name)
 		}
 
 		assert.Equal(t, isSkipped("small_note.md"), false, "should not skip small files")
@@ -393,4 +436,305 @@ func TestSkipFiles(t *testing.T) {
 		assert.Equal(t, iSync.Sources["note.md"], []LinkInfo{{rootPath: "image.png", path: "image.png", fullLink: "[](image.png)"}})
 	})
 
+	t.Run("linkable extensions", func(t *testing.T) {
+		var fs = fstest.MapFS{
+			"note.md":   {Data: []byte("")},
+			"image.png": {Data: []byte("")},
+			"spec.pdf":  {Data: []byte("")},
+			"data.CSV":  {Data: []byte("")},
+			"file.txt":  {Data: []byte("")},
+		}
+		isSkipped := func(iSync *LinkSyncer, name string) bool {
+			return skipped(t, iSync, fs, name)
+		}
+
+		iSync := New(fs, ".", nil, func(s *LinkSyncer) {
+			s.LinkableExtensions = []string{".pdf", "csv"}
+		})
+		assert.False(t, isSkipped(iSync, "note.md"), "should not skip parsable files")
+		assert.False(t, isSkipped(iSync, "spec.pdf"))
+		assert.False(t, isSkipped(iSync, "data.CSV"))
+		assert.True(t, isSkipped(iSync, "image.png"))
+		assert.True(t, isSkipped(iSync, "file.txt"))
+
+		iSync = New(fs, ".", nil, func(s *LinkSyncer) {
+			s.LinkableExtensions = []string{AnyExtension}
+		})
+		for name := range fs {
+			assert.False(t, isSkipped(iSync, name), "should track any file")
+		}
+
+		iSync = New(fs, ".", nil, func(s *LinkSyncer) {
+			s.LinkableExtensions = []string{}
+		})
+		assert.True(t, isSkipped(iSync, "image.png"), "should track only parsable files")
+		assert.False(t, isSkipped(iSync, "note.md"), "should track only parsable files")
+	})
+
+	t.Run("parsable extensions and excluded dirs", func(t *testing.T) {
+		var fs = fstest.MapFS{
+			"note.markdown":          {Data: []byte("![](image.png)")},
+			"note.md":                {Data: []byte("![](image.png)")},
+			"image.png":              {Data: []byte("")},
+			"node_modules/img.png":   {Data: []byte("")},
+			"attachments/b.markdown": {Data: []byte("")},
+		}
+		iSync := New(fs, ".", nil, func(s *LinkSyncer) {
+			s.ParsableExtensions = []string{".markdown"}
+			s.ExcludedDirs = map[string]bool{"attachments": true}
+		})
+		iSync.ProcessFiles()
+		assert.Equal(t, map[string][]LinkInfo{
+			"note.markdown": {{rootPath: "image.png", path: "image.png", fullLink: "[](image.png)"}},
+		}, iSync.Sources)
+		assert.Contains(t, iSync.Watcher.WatchedList(), "node_modules/img.png", "should watch directories that aren't excluded")
+		assert.NotContains(t, iSync.Watcher.WatchedList(), "attachments")
+	})
+
+	t.Run("ignore files", func(t *testing.T) {
+		var fs = fstest.MapFS{
+			".gitignore":              {Data: []byte("build/\n*.gen.md\n")},
+			"notes/.linksyncerignore": {Data: []byte("/drafts\n")},
+			"notes/note.md":           {Data: []byte("![](../build/img.png)")},
+			"notes/note.gen.md":       {Data: []byte("![](../build/img.png)")},
+			"notes/drafts/draft.md":   {Data: []byte("![](../../build/img.png)")},
+			"drafts/draft.md":         {Data: []byte("![](../img.png)")},
+			"build/img.png":           {Data: []byte("")},
+			"build/note.md":           {Data: []byte("")},
+		}
+		iSync := New(fs, ".", nil)
+		iSync.ProcessFiles()
+		assert.ElementsMatch(t, []string{"notes/note.md", "drafts/draft.md"}, keys(iSync.Sources))
+
+		watched := iSync.Watcher.WatchedList()
+		assert.NotContains(t, watched, "build/img.png")
+		assert.Contains(t, watched, "notes/.linksyncerignore", "should watch ignore files")
+
+		fs[".gitignore"] = &fstest.MapFile{Data: []byte("build/\n")}
+		iSync.processEvent(fswatcher.Event{Op: fswatcher.Write, Name: ".gitignore"}, &map[string]string{})
+		assert.False(t, iSync.ignore.Ignored("notes/note.gen.md", false), "should reload changed rules")
+
+		iSync = New(fs, ".", nil, func(s *LinkSyncer) {
+			s.IgnoreFiles = nil
+		})
+		iSync.ProcessFiles()
+		assert.Len(t, iSync.Sources, 5, "ignore files should be disabled")
+	})
+
+	t.Run("reload ignore files with inotify", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{".gitignore": "drafts/\n", "drafts/draft.md": "![](../img.png)", "img.png": ""})
+		watcher, err := fswatcher.NewInotify(os.DirFS(root), root)
+		if err != nil {
+			t.Skip(err)
+		}
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.Watcher = watcher
+		})
+		iSync.ProcessFiles()
+		assert.NotContains(t, iSync.Sources, "drafts/draft.md")
+
+		writeTestFiles(t, root, map[string]string{".gitignore": ""})
+		processScan(t, iSync) // the changed ignore file is read again
+		processScan(t, iSync) // the watcher compares all files
+		iSync.mu.Lock()
+		assert.Contains(t, iSync.Sources, "drafts/draft.md", "previously ignored notes should be added")
+		iSync.mu.Unlock()
+		iSync.Close()
+	})
+}
+
+// processScan runs a scan of the watcher and processes its events
+func processScan(t *testing.T, iSync *LinkSyncer) {
+	t.Helper()
+	go iSync.Watcher.Scan()
+	moves := map[string]string{}
+	for {
+		select {
+		case e := <-iSync.Watcher.Events():
+			iSync.processEvent(e, &moves)
+		case err := <-iSync.Watcher.Errors():
+			t.Error(err)
+		case <-iSync.Watcher.ScanComplete():
+			return
+		case <-time.After(time.Second * 2):
+			t.Fatal("scan wasn't completed")
+		}
+	}
+}
+
+// skipped reports whether the path is skipped by filters of LinkSyncer
+func skipped(t *testing.T, iSync *LinkSyncer, fsys fs.FS, name string) bool {
+	t.Helper()
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range iSync.filters() {
+		switch filter(name, fs.FileInfoToDirEntry(info)) {
+		case fswatcher.Skip:
+			return true
+		case fswatcher.Include:
+			return false
+		}
+	}
+	return false
+}
+
+func keys[V any](m map[string]V) []string {
+	result := []string{}
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+func TestWikiLinks(t *testing.T) {
+	var fs = fstest.MapFS{
+		"notes/index.md":                 {Data: []byte("[[Project Plan|plan]]\n![[diagram.png]]\n[[archive/old]]")},
+		"notes/projects/project plan.md": {Data: []byte("")},
+		"notes/assets/diagram.png":       {Data: []byte("")},
+		"notes/archive/x/diagram.png":    {Data: []byte("")},
+		"notes/assets/a/diagram.png":     {Data: []byte("")},
+		"notes/archive/old.md":           {Data: []byte("")},
+	}
+
+	t.Run("resolve links against the tree", func(t *testing.T) {
+		iSync := NewTestISync(fs, "notes")
+		iSync.ProcessFiles()
+
+		assert.Equal(t, []LinkInfo{
+			{rootPath: "notes/projects/project plan.md", path: "Project Plan", fullLink: "[[Project Plan|plan]]", wiki: wikiName},
+			{rootPath: "notes/archive/old.md", path: "archive/old", fullLink: "[[archive/old]]", wiki: wikiRelative},
+			{rootPath: "notes/assets/diagram.png", path: "diagram.png", fullLink: "[[diagram.png]]", wiki: wikiName},
+		}, iSync.Sources["notes/index.md"])
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		iSync := NewTestISync(fs, ".")
+		iSync.ProcessFiles()
+
+		gotData, restore := mockWriteFile(t)
+		t.Cleanup(func() { restore() })
+
+		iSync.Sync(map[string]string{
+			"notes/projects/project plan.md": "notes/projects/plan 2024.md",
+			"notes/assets/diagram.png":       "notes/assets/d.png",
+		})
+
+		expected := map[string]string{
+			"notes/index.md": "[[plan 2024|plan]]\n![[d.png]]\n[[archive/old]]",
+		}
+		assert.Equal(t, expected, *gotData)
+	})
+}
+
+func TestSyncAnyFiles(t *testing.T) {
+	var fs = fstest.MapFS{
+		"notes/index.md":  {Data: []byte("[spec](../docs/spec.pdf)\n[note](./other.md)")},
+		"notes/other.md":  {Data: []byte("other")},
+		"docs/spec.pdf":   {Data: []byte("pdf")},
+		"docs/readme.txt": {Data: []byte("txt")},
+	}
+	iSync := New(fs, ".", nil, func(s *LinkSyncer) {
+		s.LinkableExtensions = []string{".pdf"}
+	})
+	iSync.ProcessFiles()
+
+	gotData, restore := mockWriteFile(t)
+	t.Cleanup(func() { restore() })
+
+	fs["docs/specs/spec-v1.pdf"] = fs["docs/spec.pdf"]
+	delete(fs, "docs/spec.pdf")
+	fs["notes/archive/other.md"] = fs["notes/other.md"]
+	delete(fs, "notes/other.md")
+
+	go iSync.Watch(time.Millisecond)
+	time.Sleep(time.Millisecond * 40)
+	iSync.Close()
+
+	expected := map[string]string{"notes/index.md": "[spec](../docs/specs/spec-v1.pdf)\n[note](./archive/other.md)"}
+	assert.Equal(t, expected, *gotData)
+}
+
+func TestDryRun(t *testing.T) {
+	iSync, fs := NewTestISyncWithFS(".")
+	iSync.DryRun = true
+
+	from := "notes/folder/note.md"
+	to := "notes/renamed.md"
+	fs[to] = &fstest.MapFile{Data: fs[from].Data}
+	delete(fs, from)
+
+	written, restore := mockWriteFile(t)
+	t.Cleanup(func() { restore() })
+
+	iSync.Sync(map[string]string{from: to, "notes/index.png": "notes/img/index.png"})
+	assert.Empty(t, *written, "shouldn't write files")
+
+	assert.Contains(t, iSync.Linked, "notes/img/index.png", "cache should be updated")
+
+	want := []FileDiff{
+		{
+			Path: "notes/index.md",
+			Diff: "--- a/notes/index.md\n+++ b/notes/index.md\n@@ -1 +1 @@\n-![alt text](./index.png)\n\\ No newline at end of file\n+![alt text](./img/index.png)\n\\ No newline at end of file\n",
+		},
+		{
+			Path: to,
+			Diff: "--- a/notes/renamed.md\n+++ b/notes/renamed.md\n@@ -1 +1 @@\n" +
+				`-![alt text](./assets/image01.png)\n![alt text](./assets/image02.png)` + "\n\\ No newline at end of file\n" +
+				`+![alt text](./folder/assets/image01.png)\n![alt text](./folder/assets/image02.png)` + "\n\\ No newline at end of file\n",
+		},
+	}
+	assert.Equal(t, want, iSync.TakeDiffs())
+	assert.Empty(t, iSync.TakeDiffs(), "diffs should be taken only once")
+
+	t.Run("use planned content", func(t *testing.T) {
+		iSync.Sync(map[string]string{"notes/img/index.png": "notes/index.png"})
+		want := []FileDiff{{
+			Path: "notes/index.md",
+			Diff: "--- a/notes/index.md\n+++ b/notes/index.md\n@@ -1 +1 @@\n-![alt text](./img/index.png)\n\\ No newline at end of file\n+![alt text](./index.png)\n\\ No newline at end of file\n",
+		}}
+		assert.Equal(t, want, iSync.TakeDiffs())
+		assert.Empty(t, *written, "shouldn't write files")
+	})
+}
+
+func TestSyncReferenceLinks(t *testing.T) {
+	var fs = fstest.MapFS{
+		"notes/index.md": {Data: []byte("![a][pic] and ![b][pic]\n\n[pic]: ../img.png \"title\"\n[note]: ./other.md")},
+		"notes/other.md": {Data: []byte("other")},
+		"img.png":        {Data: []byte("png")},
+	}
+	iSync := NewTestISync(fs, ".")
+	iSync.ProcessFiles()
+	assert.Len(t, iSync.Sources["notes/index.md"], 2, "should save definitions instead of usages")
+
+	gotData, restore := mockWriteFile(t)
+	t.Cleanup(func() { restore() })
+
+	iSync.Sync(map[string]string{"img.png": "assets/img.png", "notes/other.md": "other.md"})
+
+	expected := map[string]string{"notes/index.md": "![a][pic] and ![b][pic]\n\n[pic]: ../assets/img.png \"title\"\n[note]: ../other.md"}
+	assert.Equal(t, expected, *gotData)
+}
+
+func TestSyncFragments(t *testing.T) {
+	var fs = fstest.MapFS{
+		"notes/index.md": {Data: []byte("[see](../guide.md#install-steps) ![](../img.png?v=2) [x](<../guide.md?raw=true#usage>)")},
+		"guide.md":       {Data: []byte("# Guide")},
+		"img.png":        {Data: []byte("png")},
+	}
+	iSync := NewTestISync(fs, ".")
+	iSync.ProcessFiles()
+	assert.Contains(t, iSync.Linked["guide.md"], "notes/index.md")
+	assert.Contains(t, iSync.Linked["img.png"], "notes/index.md")
+
+	gotData, restore := mockWriteFile(t)
+	t.Cleanup(func() { restore() })
+
+	iSync.Sync(map[string]string{"guide.md": "docs/guide.md", "img.png": "assets/img.png"})
+
+	expected := map[string]string{"notes/index.md": "[see](../docs/guide.md#install-steps) ![](../assets/img.png?v=2) [x](<../docs/guide.md?raw=true#usage>)"}
+	assert.Equal(t, expected, *gotData)
 }
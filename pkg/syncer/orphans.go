@@ -0,0 +1,92 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// linkedPaths returns set of the linked files with paths relative to the file system
+func (s *LinkSyncer) linkedPaths() map[string]Empty {
+	result := map[string]Empty{}
+	for linked := range s.Linked {
+		if filepath.IsAbs(linked) {
+			rel, err := filepath.Rel(s.root, linked)
+			if err != nil {
+				continue
+			}
+			linked = filepath.ToSlash(filepath.Join(s.rootDir(), rel))
+		}
+		result[linked] = Empty{}
+	}
+	return result
+}
+
+// Orphans returns watched images that aren't referenced by any note
+func (s *LinkSyncer) Orphans() []string {
+	watched := s.Watcher.WatchedList() // the watcher can wait for the lock while hashing files
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linked := s.linkedPaths()
+	result := []string{}
+	for path, fi := range watched {
+		if (*fi).IsDir() || !imageFiles.MatchString(path) {
+			continue
+		}
+		if _, ok := linked[path]; !ok {
+			result = append(result, path)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// QuarantineFiles moves files into the given directory keeping their relative paths.
+// Relative dir is resolved against the root directory. Files aren't moved in dry-run mode.
+func (s *LinkSyncer) QuarantineFiles(files []string, dir string) error {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.root, dir)
+	}
+	errs := []error{}
+	for _, f := range files {
+		target := filepath.Join(dir, f)
+		if s.DryRun {
+			s.log.With("from", f, "to", target).Info("File would be moved to quarantine: %s -> %s", f, target)
+			continue
+		}
+		if _, err := os.Stat(target); err == nil {
+			errs = append(errs, fmt.Errorf("couldn't move %s: %s already exists", f, target))
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err == nil {
+			err = os.Rename(filepath.Join(s.root, f), target)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.log.With("from", f, "to", target).Info("File moved to quarantine: %s -> %s", f, target)
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteFiles removes files from the disk. Files aren't removed in dry-run mode.
+func (s *LinkSyncer) DeleteFiles(files []string) error {
+	errs := []error{}
+	for _, f := range files {
+		if s.DryRun {
+			s.log.With("source", f).Info("File would be deleted: %s", f)
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.root, f)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.log.With("source", f).Info("File deleted: %s", f)
+	}
+	return errors.Join(errs...)
+}
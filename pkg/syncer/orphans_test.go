@@ -0,0 +1,60 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphans(t *testing.T) {
+	t.Run("find orphans", func(t *testing.T) {
+		var fs = fstest.MapFS{
+			"index.md":            {Data: []byte("![](assets/used.png) ![[embed.jpg]]")},
+			"notes/note.md":       {Data: []byte("![](../assets/Other.GIF)")},
+			"assets/used.png":     {Data: []byte("1")},
+			"assets/Other.GIF":    {Data: []byte("2")},
+			"assets/unused.png":   {Data: []byte("3")},
+			"notes/embed.jpg":     {Data: []byte("4")},
+			"notes/orphan.webp":   {Data: []byte("5")},
+			"docs/unlinked.pdf":   {Data: []byte("6")},
+			"docs/not-linked.txt": {Data: []byte("7")},
+		}
+		iSync := NewTestISync(fs, ".")
+		iSync.ProcessFiles()
+
+		assert.Equal(t, []string{"assets/unused.png", "notes/orphan.webp"}, iSync.Orphans())
+	})
+
+	t.Run("quarantine and delete", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{
+			"index.md":          "![](img.png)",
+			"img.png":           "1",
+			"assets/orphan.png": "2",
+			"old.png":           "3",
+		})
+		iSync := New(os.DirFS(root), root, nil)
+		iSync.ProcessFiles()
+		orphans := iSync.Orphans()
+		assert.Equal(t, []string{"assets/orphan.png", "old.png"}, orphans)
+
+		iSync.DryRun = true
+		assert.NoError(t, iSync.QuarantineFiles(orphans[:1], "quarantine"))
+		assert.NoError(t, iSync.DeleteFiles(orphans[1:]))
+		assert.FileExists(t, filepath.Join(root, "assets/orphan.png"), "files shouldn't be moved in dry-run mode")
+		assert.FileExists(t, filepath.Join(root, "old.png"), "files shouldn't be deleted in dry-run mode")
+		assert.NoDirExists(t, filepath.Join(root, "quarantine"))
+
+		iSync.DryRun = false
+		assert.NoError(t, iSync.QuarantineFiles(orphans[:1], "quarantine"))
+		assert.Equal(t, "2", readTestFile(t, filepath.Join(root, "quarantine/assets/orphan.png")))
+		assert.NoFileExists(t, filepath.Join(root, "assets/orphan.png"))
+
+		assert.NoError(t, iSync.DeleteFiles(orphans[1:]))
+		assert.NoFileExists(t, filepath.Join(root, "old.png"))
+		assert.FileExists(t, filepath.Join(root, "img.png"))
+	})
+}
@@ -1,10 +1,12 @@
 package syncer
 
 import (
-	"bytes"
 	"net/url"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	mdParser "github.com/flytaly/linksyncer/pkg/parser"
 )
@@ -13,8 +15,21 @@ type LinkInfo struct {
 	rootPath string
 	path     string
 	fullLink string
+	wiki     wikiStyle // how the target of a wiki link is written, zero for markdown links
+	wikiBase string    // directory that a wiki link with wikiAbsolute style is resolved against
+	anchor   string    // heading that the link points to, without "#"
 }
 
+// wikiStyle describes the form of a wiki link's target
+type wikiStyle int
+
+const (
+	notWiki      wikiStyle = iota
+	wikiName               // [[note]], file name only
+	wikiRelative           // [[../folder/note]], path relative to the note
+	wikiAbsolute           // [[folder/note]], path relative to the root directory
+)
+
 type MovedLink struct {
 	to   string
 	link LinkInfo
@@ -23,21 +38,64 @@ type MovedLink struct {
 type ContentLink struct {
 	content string
 	dest    string
+	wiki    bool
+	pos     mdParser.Range // position of the link in the content
+	destPos mdParser.Range // position of the raw destination in the content
 }
 
 func GetLinksFromMD(content string) (links []ContentLink, images []ContentLink) {
 	p := mdParser.New()
 	p.Parse([]byte(content))
 	links_, imgs_ := p.LinksAndImages()
+	// usages of reference definitions are skipped, because the destination is in the definition
 	for _, link := range links_ {
-		links = append(links, ContentLink{string(link.GetContent()), string(link.Destination)})
+		if link.RefID == nil {
+			links = append(links, ContentLink{string(link.GetContent()), string(link.Destination), link.Wiki, link.Range, link.DestRange})
+		}
 	}
 	for _, img := range imgs_ {
-		images = append(images, ContentLink{string(img.GetContent()), string(img.Destination)})
+		if img.RefID == nil {
+			images = append(images, ContentLink{string(img.GetContent()), string(img.Destination), img.Wiki, img.Range, img.DestRange})
+		}
+	}
+	for _, ref := range p.References() {
+		cl := ContentLink{content: string(ref.GetContent()), dest: string(ref.Destination), pos: ref.Range, destPos: ref.DestRange}
+		if ref.Image {
+			images = append(images, cl)
+		} else {
+			links = append(links, cl)
+		}
 	}
 	return links, images
 }
 
+// linkPath returns the path part of the link's destination as it's saved in LinkInfo
+func (l ContentLink) linkPath() string {
+	if l.wiki {
+		path, _ := wikiTarget(l.dest)
+		return path
+	}
+	path, _ := splitDest(l.dest)
+	return path
+}
+
+// contentLinks returns links and images found in the note's content.
+// All parsable files are considered Markdown notes regardless of their extension.
+func contentLinks(content string) (links []ContentLink, images []ContentLink) {
+	return GetLinksFromMD(content)
+}
+
+// findLink returns the first link in the content that corresponds to the LinkInfo
+// and whose position isn't in the skip set
+func findLink(nodes []ContentLink, link LinkInfo, skip map[int]bool) (ContentLink, bool) {
+	for _, n := range nodes {
+		if n.content == link.fullLink && n.linkPath() == link.path && !skip[n.pos.Start] {
+			return n, true
+		}
+	}
+	return ContentLink{}, false
+}
+
 func filterLinks(paths []ContentLink) []ContentLink {
 	var result = []ContentLink{}
 
@@ -64,56 +122,220 @@ func processLinks(filePath string, links []ContentLink) []LinkInfo {
 	result := []LinkInfo{}
 
 	for _, l := range links {
-		link, path := l.content, l.dest
+		if l.wiki {
+			if info, ok := wikiLinkInfo(filePath, l); ok {
+				result = append(result, info)
+			}
+			continue
+		}
+		// the query string and the fragment aren't a part of the file's path
+		path, _ := splitDest(l.dest)
+		anchor := destAnchor(l.dest)
 		decoded := decodePath(path)
 
 		if filepath.IsAbs(path) {
-			result = append(result, LinkInfo{fullLink: link, path: path, rootPath: decoded})
+			result = append(result, LinkInfo{fullLink: l.content, path: path, rootPath: decoded, anchor: anchor})
 			continue
 		}
 		dir := filepath.Dir(filePath)
 		// save as path with slash for consistency on Windows
-		info := LinkInfo{fullLink: link, path: path, rootPath: filepath.ToSlash(filepath.Join(dir, decoded))}
+		info := LinkInfo{fullLink: l.content, path: path, rootPath: filepath.ToSlash(filepath.Join(dir, decoded)), anchor: anchor}
 		result = append(result, info)
 	}
 
 	return result
 }
 
-// Extracts links from a file's content. filePath argument should be absolute.
-func GetLinksFromFile(filePath string, content string) (links []LinkInfo, images []LinkInfo) {
-	var imgList, linkList []ContentLink
+// wikiTarget splits a wiki link's destination into the path and the heading part
+func wikiTarget(dest string) (path, heading string) {
+	if i := strings.IndexByte(dest, '#'); i >= 0 {
+		return strings.TrimSpace(dest[:i]), dest[i:]
+	}
+	return dest, ""
+}
 
-	switch strings.ToLower(filepath.Ext(filePath)) {
-	case ".md":
-		linkList, imgList = GetLinksFromMD(content)
+// wikiFileName adds ".md" extension to a wiki target if it has no extension,
+// because notes are usually referenced without it
+func wikiFileName(target string) string {
+	if filepath.Ext(target) == "" {
+		return target + ParsableFilesExtension
 	}
+	return target
+}
+
+// wikiLinkInfo creates LinkInfo for a wiki link. The target is resolved relative
+// to the note's directory; LinkSyncer can then resolve it against the indexed tree.
+func wikiLinkInfo(filePath string, l ContentLink) (LinkInfo, bool) {
+	target, heading := wikiTarget(l.dest)
+	if target == "" { // link to a heading in the same note
+		return LinkInfo{}, false
+	}
+	info := LinkInfo{fullLink: l.content, path: target, wiki: wikiName, anchor: wikiAnchor(heading)}
+	if strings.Contains(target, "/") {
+		info.wiki = wikiRelative
+	}
+	info.rootPath = filepath.ToSlash(filepath.Join(filepath.Dir(filePath), wikiFileName(target)))
+	return info, true
+}
+
+// Extracts links from a file's content. filePath argument should be absolute.
+func GetLinksFromFile(filePath string, content string) (links []LinkInfo, images []LinkInfo) {
+	linkList, imgList := contentLinks(content)
 
 	links = processLinks(filePath, linkList)
 	images = processLinks(filePath, imgList)
 	return links, images
 }
 
-// ReplaceLinks updates links in the file
+// textEdit replaces the text in the given range
+type textEdit struct {
+	pos  mdParser.Range
+	text string
+}
+
+// ReplaceLinks updates links in the file.
+// Only destinations of the parsed links are replaced, so the same text elsewhere
+// (e.g. in code blocks) remains untouched.
 func ReplaceLinks(fPath string, fileContent []byte, moves []MovedLink) []byte {
-	result := fileContent
+	return replaceLinks(fPath, fileContent, moves, movedPath)
+}
 
+// replaceLinks updates links in the file using newPath to get destinations of the moved links
+func replaceLinks(fPath string, fileContent []byte, moves []MovedLink, newPath func(string, MovedLink) string) []byte {
+	links, images := contentLinks(string(fileContent))
+	nodes := append(links, images...)
+
+	edits := []textEdit{}
+	replaced := map[int]bool{} // start positions of the replaced destinations
 	for _, move := range moves {
-		targpath := ""
-		if !filepath.IsAbs(move.link.path) {
-			targpath, _ = filepath.Rel(filepath.Dir(fPath), move.to)
+		to := newPath(fPath, move)
+		for _, n := range nodes {
+			if n.content != move.link.fullLink || n.linkPath() != move.link.path || !n.destPos.Valid() {
+				continue
+			}
+			pos := n.destPos
+			text := to
+			if n.wiki { // keep the heading
+				pos.End = pos.Start + len(move.link.path)
+			} else {
+				text = getDestStyle(fileContent, pos).format(to)
+				// keep the query string and the fragment
+				_, suffix := splitDest(string(fileContent[pos.Start:pos.End]))
+				pos.End -= len(suffix)
+			}
+			if replaced[pos.Start] {
+				continue
+			}
+			replaced[pos.Start] = true
+			edits = append(edits, textEdit{pos, text})
 		}
-		if targpath == "" {
-			targpath = move.to
+	}
+
+	return applyEdits(fileContent, edits)
+}
+
+// movedPath returns the new destination of the moved link
+func movedPath(fPath string, move MovedLink) string {
+	if move.link.wiki != notWiki {
+		return wikiPath(fPath, move)
+	}
+
+	targpath := ""
+	if !filepath.IsAbs(move.link.path) {
+		targpath, _ = filepath.Rel(filepath.Dir(fPath), move.to)
+	}
+	if targpath == "" {
+		targpath = move.to
+	}
+	return filepath.ToSlash(targpath)
+}
+
+// percentEncoded matches percent-encoded bytes of non-ASCII characters
+var percentEncoded = regexp.MustCompile(`%[89A-Fa-f][0-9A-Fa-f]`)
+
+// destStyle describes how the destination of a link is written,
+// so the new destination is written the same way
+type destStyle struct {
+	angle      bool // <path with spaces.png>
+	dotSlash   bool // ./img.png
+	encoded    bool // spaces are percent-encoded inside angle brackets
+	encodedUTF bool // non-ASCII characters are percent-encoded
+	escaped    bool // parentheses are escaped with a backslash
+}
+
+// getDestStyle returns the style of the raw destination in the given range of the content
+func getDestStyle(content []byte, pos mdParser.Range) destStyle {
+	raw, _ := splitDest(string(content[pos.Start:pos.End]))
+	return destStyle{
+		angle:      pos.Start > 0 && pos.End < len(content) && content[pos.Start-1] == '<' && content[pos.End] == '>',
+		dotSlash:   strings.HasPrefix(raw, "./"),
+		encoded:    strings.Contains(raw, "%20"),
+		encodedUTF: percentEncoded.MatchString(raw),
+		escaped:    strings.Contains(raw, "\\(") || strings.Contains(raw, "\\)"),
+	}
+}
+
+// format writes the path in the style of the original destination.
+// Spaces are always encoded outside of angle brackets, because they would end the destination.
+func (st destStyle) format(path string) string {
+	if st.dotSlash && !strings.HasPrefix(path, "../") && !strings.HasPrefix(path, "/") {
+		path = "./" + path
+	}
+	var sb strings.Builder
+	for _, r := range path {
+		switch {
+		case r == ' ' && (!st.angle || st.encoded):
+			sb.WriteString("%20")
+		case r == '%' && (st.encoded || st.encodedUTF):
+			sb.WriteString("%25")
+		case r >= utf8.RuneSelf && st.encodedUTF:
+			sb.WriteString(url.PathEscape(string(r)))
+		case (r == '(' || r == ')') && st.escaped && !st.angle,
+			(r == '<' || r == '>') && st.angle:
+			sb.WriteString("\\" + string(r))
+		default:
+			sb.WriteRune(r)
 		}
+	}
+	return sb.String()
+}
 
-		// encode spaces
-		targpath = strings.Replace(targpath, " ", "%20", -1)
+// applyEdits returns a copy of the content with non-overlapping edits applied
+func applyEdits(content []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos.Start < edits[j].pos.Start })
 
-		// Replace path in the link and then replace link in the file
-		newLink := strings.Replace(move.link.fullLink, move.link.path, targpath, 1)
-		result = bytes.ReplaceAll(result, []byte(move.link.fullLink), []byte(newLink))
+	result := make([]byte, 0, len(content))
+	last := 0
+	for _, e := range edits {
+		if e.pos.Start < last || e.pos.End > len(content) {
+			continue
+		}
+		result = append(result, content[last:e.pos.Start]...)
+		result = append(result, e.text...)
+		last = e.pos.End
 	}
+	return append(result, content[last:]...)
+}
 
-	return result
+// wikiPath returns the new target of the moved wiki link written in the same style
+func wikiPath(fPath string, move MovedLink) string {
+	var targpath string
+	switch move.link.wiki {
+	case wikiName:
+		targpath = filepath.Base(move.to)
+	case wikiAbsolute:
+		targpath, _ = filepath.Rel(move.link.wikiBase, move.to)
+	default:
+		targpath, _ = filepath.Rel(filepath.Dir(fPath), move.to)
+	}
+	if targpath == "" {
+		targpath = move.to
+	}
+	targpath = filepath.ToSlash(targpath)
+
+	// keep the extension omitted if it was
+	if filepath.Ext(move.link.path) == "" {
+		targpath = strings.TrimSuffix(targpath, ParsableFilesExtension)
+	}
+	return targpath
 }
@@ -3,6 +3,7 @@ package syncer
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/flytaly/linksyncer/testutils"
@@ -76,7 +77,7 @@ func TestReplaceImageLinks(t *testing.T) {
 		{
 			Move{j(dir, "./assets/image2.gif"), j(dir, "./assets/subfolder/i.png"), "./assets/images2.gif"},
 			`![alt text](./assets/images2.gif "title")`,
-			`![alt text](assets/subfolder/i.png "title")`,
+			`![alt text](./assets/subfolder/i.png "title")`,
 		},
 		{
 			// Absolute path
@@ -96,7 +97,9 @@ func TestReplaceImageLinks(t *testing.T) {
 			md := fmt.Sprintf("# Test markdown %d\n## Text with images\n%s\ntext after the image...", i, v.linkFrom)
 			want := fmt.Sprintf("# Test markdown %d\n## Text with images\n%s\ntext after the image...", i, v.linkTo)
 
-			link := []MovedLink{{to: v.move.to, link: LinkInfo{rootPath: v.move.from, path: v.move.link, fullLink: v.linkFrom}}}
+			// content of the image node doesn't include "!"
+			fullLink := strings.TrimPrefix(v.linkFrom, "!")
+			link := []MovedLink{{to: v.move.to, link: LinkInfo{rootPath: v.move.from, path: v.move.link, fullLink: fullLink}}}
 			got := string(ReplaceLinks(filePath, []byte(md), link))
 			assertText(t, got, want)
 		})
@@ -110,3 +113,152 @@ func assertText(t testing.TB, got, want string) {
 		t.Errorf("\n==Got=>\n%s\n==Want=>\n%s\n==Diff=>\n%s\n%s", got, want, d1, d2)
 	}
 }
+
+func TestGetWikiLinks(t *testing.T) {
+	md := "[[Note Name]]\n![[diagram.png|200]]\n[[../folder/note|alias]]\n[[#heading]]"
+	links, images := GetLinksFromFile("notes/note.md", md)
+
+	assert.Equal(t, []LinkInfo{
+		{rootPath: "notes/Note Name.md", path: "Note Name", fullLink: "[[Note Name]]", wiki: wikiName},
+		{rootPath: "folder/note.md", path: "../folder/note", fullLink: "[[../folder/note|alias]]", wiki: wikiRelative},
+	}, links)
+	assert.Equal(t, []LinkInfo{
+		{rootPath: "notes/diagram.png", path: "diagram.png", fullLink: "[[diagram.png|200]]", wiki: wikiName},
+	}, images)
+}
+
+func TestReplaceWikiLinks(t *testing.T) {
+	filePath := "notes/my_note/note.md"
+
+	tests := []struct {
+		link     LinkInfo
+		to       string
+		linkFrom string
+		linkTo   string
+	}{
+		{
+			LinkInfo{rootPath: "notes/my_note/note1.md", path: "note1", fullLink: "[[note1|alias]]", wiki: wikiName},
+			"notes/other/note2.md",
+			"[[note1|alias]]",
+			"[[note2|alias]]",
+		},
+		{
+			LinkInfo{rootPath: "notes/assets/img.png", path: "img.png", fullLink: "[[img.png]]", wiki: wikiName},
+			"notes/assets/renamed img.png",
+			"![[img.png]]",
+			"![[renamed img.png]]",
+		},
+		{
+			LinkInfo{rootPath: "notes/folder/note.md", path: "../folder/note", fullLink: "[[../folder/note]]", wiki: wikiRelative},
+			"notes/my_note/sub/note.md",
+			"[[../folder/note]]",
+			"[[sub/note]]",
+		},
+		{
+			LinkInfo{rootPath: "notes/folder/note.md", path: "folder/note.md", fullLink: "[[folder/note.md#head]]", wiki: wikiAbsolute, wikiBase: "notes"},
+			"notes/other/note.md",
+			"[[folder/note.md#head]]",
+			"[[other/note.md#head]]",
+		},
+	}
+
+	for i, v := range tests {
+		t.Run(fmt.Sprintf("Replace case %d", i), func(t *testing.T) {
+			md := fmt.Sprintf("# Test markdown %d\n%s\ntext after the link...", i, v.linkFrom)
+			want := fmt.Sprintf("# Test markdown %d\n%s\ntext after the link...", i, v.linkTo)
+
+			got := string(ReplaceLinks(filePath, []byte(md), []MovedLink{{to: v.to, link: v.link}}))
+			assertText(t, got, want)
+		})
+	}
+}
+
+func TestReplaceLinksByPosition(t *testing.T) {
+	filePath := "notes/note.md"
+	link := LinkInfo{rootPath: "notes/img.png", path: "img.png", fullLink: "[](img.png)"}
+	moves := []MovedLink{{to: "notes/assets/img.png", link: link}}
+
+	t.Run("keep the same text outside of links", func(t *testing.T) {
+		md := "![](img.png) `[](img.png)`\n\n```\n![](img.png)\n```\n[](img.png)"
+		want := "![](assets/img.png) `[](img.png)`\n\n```\n![](img.png)\n```\n[](assets/img.png)"
+		assertText(t, string(ReplaceLinks(filePath, []byte(md), moves)), want)
+	})
+
+	t.Run("code blocks", func(t *testing.T) {
+		md := "Example:\n\n    ![](img.png)\n\n~~~md\n![](img.png)\n~~~\n\n" +
+			"- item\n\n  ```\n  ![](img.png)\n  ```\n\n> ```\n> ![](img.png)\n> ```\n\n" +
+			"````\n```\n![](img.png)\n```\n````\n\n``` [](img.png) ``` ![](img.png)"
+		want := "Example:\n\n    ![](img.png)\n\n~~~md\n![](img.png)\n~~~\n\n" +
+			"- item\n\n  ```\n  ![](img.png)\n  ```\n\n> ```\n> ![](img.png)\n> ```\n\n" +
+			"````\n```\n![](img.png)\n```\n````\n\n``` [](img.png) ``` ![](assets/img.png)"
+		assertText(t, string(ReplaceLinks(filePath, []byte(md), moves)), want)
+	})
+
+	t.Run("wiki links in code", func(t *testing.T) {
+		wiki := LinkInfo{rootPath: "notes/sub/img.png", path: "sub/img.png", fullLink: "[[sub/img.png]]", wiki: wikiRelative}
+		md := "![[sub/img.png]] `![[sub/img.png]]`\n\n```\n![[sub/img.png]]\n```\n\n    ![[sub/img.png]]"
+		want := "![[assets/img.png]] `![[sub/img.png]]`\n\n```\n![[sub/img.png]]\n```\n\n    ![[sub/img.png]]"
+		got := ReplaceLinks(filePath, []byte(md), []MovedLink{{to: "notes/assets/img.png", link: wiki}})
+		assertText(t, string(got), want)
+	})
+
+	t.Run("CRLF line endings", func(t *testing.T) {
+		md := "# Title\r\n\r\ntext ![](img.png)\r\n![](img.png)\r\n"
+		want := "# Title\r\n\r\ntext ![](assets/img.png)\r\n![](assets/img.png)\r\n"
+		assertText(t, string(ReplaceLinks(filePath, []byte(md), moves)), want)
+	})
+
+	t.Run("reference definition", func(t *testing.T) {
+		ref := LinkInfo{rootPath: "notes/img.png", path: "img.png", fullLink: "[pic]: img.png"}
+		md := "![a][pic] ![b][pic]\n\n[pic]: img.png \"title\""
+		want := "![a][pic] ![b][pic]\n\n[pic]: assets/img.png \"title\""
+		got := ReplaceLinks(filePath, []byte(md), []MovedLink{{to: "notes/assets/img.png", link: ref}})
+		assertText(t, string(got), want)
+	})
+}
+
+func TestReplaceLinksStyle(t *testing.T) {
+	filePath := "notes/note.md"
+	tests := []struct {
+		name string
+		md   string
+		to   string
+		want string
+	}{
+		{"angle brackets", `![](<my img.png> "my img.png")`, "notes/sub/new img.png", `![](<sub/new img.png> "my img.png")`},
+		{"angle brackets with encoding", `![](<my%20img.png>)`, "notes/sub/new img.png", `![](<sub/new%20img.png>)`},
+		{"title with the path", `![](img.png "img.png")`, "notes/sub/img.png", `![](sub/img.png "img.png")`},
+		{"spaces", `![](my%20img.png)`, "notes/new img.png", `![](new%20img.png)`},
+		{"raw unicode", `![](фото.png)`, "notes/новое фото.png", `![](новое%20фото.png)`},
+		{"encoded unicode", `![](%D1%84.png)`, "notes/фото.png", `![](%D1%84%D0%BE%D1%82%D0%BE.png)`},
+		{"dot slash", `![](./img.png)`, "notes/sub/img.png", `![](./sub/img.png)`},
+		{"dot slash to the parent", `![](./img.png)`, "img.png", `![](../img.png)`},
+		{"escaped parentheses", `![](img\(1\).png)`, "notes/img(2).png", `![](img\(2\).png)`},
+		{"fragment", `![](img.png#center)`, "notes/sub/img.png", `![](sub/img.png#center)`},
+		{"query string", `![](<my img.png?v=2#x> "title")`, "notes/new img.png", `![](<new img.png?v=2#x> "title")`},
+		{"reference definition", "![a][pic]\n\n[pic]: <./my img.png> 'title'", "notes/sub/new img.png", "![a][pic]\n\n[pic]: <./sub/new img.png> 'title'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, images := GetLinksFromFile(filePath, tt.md)
+			if !assert.Len(t, images, 1) {
+				return
+			}
+			got := ReplaceLinks(filePath, []byte(tt.md), []MovedLink{{to: tt.to, link: images[0]}})
+			assertText(t, string(got), tt.want)
+		})
+	}
+}
+
+func TestGetReferenceLinks(t *testing.T) {
+	md := "![a][pic] [text][note] [^1]\n\n[pic]: ../img.png \"title\"\n[note]: <./note2.md>\n[unused]: ./other.md\n[^1]: footnote"
+	links, images := GetLinksFromFile("notes/note.md", md)
+
+	assert.Equal(t, []LinkInfo{
+		{rootPath: "notes/note2.md", path: "./note2.md", fullLink: "[note]: <./note2.md>"},
+		{rootPath: "notes/other.md", path: "./other.md", fullLink: "[unused]: ./other.md"},
+	}, links)
+	assert.Equal(t, []LinkInfo{
+		{rootPath: "img.png", path: "../img.png", fullLink: "[pic]: ../img.png"},
+	}, images)
+}
@@ -0,0 +1,140 @@
+package syncer
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Styles of the link paths
+const (
+	LinkStyleKeep     = "keep"     // every link keeps its own style
+	LinkStyleRelative = "relative" // ../images/x.png, relative to the note
+	LinkStyleRoot     = "root"     // /images/x.png, relative to the watched directory
+	LinkStyleAbsolute = "absolute" // /home/user/notes/images/x.png, absolute path in the file system
+)
+
+// absRoot returns the absolute path to the watched directory
+func (s *LinkSyncer) absRoot() string {
+	abs, err := filepath.Abs(s.root)
+	if err != nil {
+		return s.root
+	}
+	return abs
+}
+
+// insideRoot returns the path relative to the watched directory
+// if the absolute file system path is inside it
+func (s *LinkSyncer) insideRoot(absPath string) (string, bool) {
+	rel, err := filepath.Rel(s.absRoot(), filepath.FromSlash(absPath))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// resolveRootLinks resolves links that start with "/" against the watched directory.
+// Absolute file system paths that point inside the watched directory are resolved too,
+// while existing files outside of it keep their absolute paths.
+func (s *LinkSyncer) resolveRootLinks(links []LinkInfo) {
+	for i, link := range links {
+		if link.wiki != notWiki || !strings.HasPrefix(link.path, "/") {
+			continue
+		}
+		rel, ok := s.insideRoot(link.rootPath)
+		if !ok {
+			if _, err := os.Stat(filepath.FromSlash(link.rootPath)); err == nil {
+				continue
+			}
+			rel = strings.TrimPrefix(link.rootPath, "/")
+		}
+		links[i].rootPath = path.Join(s.rootDir(), rel)
+	}
+}
+
+// outsideRoot reports whether the linked file is outside of the watched directory
+func outsideRoot(rootPath string) bool {
+	return filepath.IsAbs(rootPath) || !fs.ValidPath(rootPath)
+}
+
+// linkStyle returns the style of the link's path
+func (s *LinkSyncer) linkStyle(link LinkInfo) string {
+	if !strings.HasPrefix(link.path, "/") {
+		return LinkStyleRelative
+	}
+	if _, ok := s.insideRoot(decodePath(link.path)); ok {
+		return LinkStyleAbsolute
+	}
+	return LinkStyleRoot
+}
+
+// movedPath returns the new destination of the moved link in the configured style.
+// Links to files outside of the watched directory are always relative.
+func (s *LinkSyncer) movedPath(fPath string, move MovedLink) string {
+	if move.link.wiki != notWiki {
+		return wikiPath(fPath, move)
+	}
+	style := s.LinkStyle
+	if style == LinkStyleKeep || style == "" {
+		style = s.linkStyle(move.link)
+	}
+
+	rel, err := filepath.Rel(s.rootDir(), move.to)
+	inside := err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	switch {
+	case style == LinkStyleRoot && inside:
+		return "/" + filepath.ToSlash(rel)
+	case style == LinkStyleAbsolute && inside:
+		return filepath.ToSlash(filepath.Join(s.absRoot(), rel))
+	}
+	targpath, _ := filepath.Rel(filepath.Dir(fPath), move.to)
+	return filepath.ToSlash(targpath)
+}
+
+// replaceLinks updates links in the file writing new paths in the configured style
+func (s *LinkSyncer) replaceLinks(fPath string, content []byte, moves []MovedLink) []byte {
+	return replaceLinks(fPath, content, moves, s.movedPath)
+}
+
+// Normalize rewrites links in all notes into LinkStyle and returns paths of the rewritten notes.
+// Wiki links and links to files outside of the watched directory keep their style.
+func (s *LinkSyncer) Normalize() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beginBatch(nil)
+	defer s.commitBatch()
+
+	sources := []string{}
+	for source := range s.Sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	rewritten := []string{}
+	for _, source := range sources {
+		links := []MovedLink{}
+		for _, link := range s.Sources[source] {
+			if link.wiki == notWiki && !strings.HasPrefix(link.path, "#") && !outsideRoot(link.rootPath) {
+				links = append(links, MovedLink{to: link.rootPath, link: link})
+			}
+		}
+		if len(links) == 0 {
+			continue
+		}
+		content, err := s.readContent(source)
+		if err != nil {
+			s.log.With("source", source).Error("Couldn't read file. %s", err)
+			continue
+		}
+		if string(s.replaceLinks(source, content, links)) == string(content) {
+			continue
+		}
+		if s.updateLinks(source, links) {
+			rewritten = append(rewritten, source)
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,90 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkStyle(t *testing.T) {
+	setup := func(t *testing.T, style string) (*LinkSyncer, string) {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{
+			"docs/note.md": "![](../images/a.png)\n![](/images/b.png)\n![](" + filepath.ToSlash(root) + "/images/c.png)\n" +
+				"[site](https://example.com) [[other]] [top](#top) ![](../../outside.png)\n\n# Top",
+			"docs/other.md": "no links",
+			"images/a.png":  "a",
+			"images/b.png":  "b",
+			"images/c.png":  "c",
+		})
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.LinkStyle = style
+		})
+		iSync.ProcessFiles()
+		return iSync, root
+	}
+	rest := "\n[site](https://example.com) [[other]] [top](#top) ![](../../outside.png)\n\n# Top"
+
+	t.Run("resolve root links", func(t *testing.T) {
+		iSync, _ := setup(t, LinkStyleKeep)
+		for _, img := range []string{"images/a.png", "images/b.png", "images/c.png"} {
+			assert.Contains(t, iSync.Linked[img], "docs/note.md")
+		}
+		broken := iSync.Check()
+		if assert.Len(t, broken, 1) {
+			assert.Equal(t, "../../outside.png", broken[0].Dest)
+		}
+	})
+
+	t.Run("keep style of the moved links", func(t *testing.T) {
+		iSync, root := setup(t, LinkStyleKeep)
+		moves := map[string]string{"images/a.png": "media/a.png", "images/b.png": "media/b.png", "images/c.png": "media/c.png"}
+		for from, to := range moves {
+			writeTestFiles(t, root, map[string]string{to: "img"})
+			assert.NoError(t, os.Remove(filepath.Join(root, from)))
+		}
+		iSync.Sync(moves)
+		want := "![](../media/a.png)\n![](/media/b.png)\n![](" + filepath.ToSlash(root) + "/media/c.png)" + rest
+		assert.Equal(t, want, readTestFile(t, filepath.Join(root, "docs/note.md")))
+	})
+
+	t.Run("files outside of the root", func(t *testing.T) {
+		outside := t.TempDir()
+		writeTestFiles(t, outside, map[string]string{"ext.png": "ext"})
+		ext := filepath.ToSlash(filepath.Join(outside, "ext.png"))
+		root := t.TempDir()
+		md := "![](" + ext + ") ![](/images/a.png)"
+		writeTestFiles(t, root, map[string]string{"docs/note.md": md, "images/a.png": "a"})
+		iSync := New(os.DirFS(root), root, nil, func(s *LinkSyncer) {
+			s.LinkStyle = LinkStyleRelative
+		})
+		iSync.ProcessFiles()
+
+		assert.Empty(t, iSync.Check(), "existing absolute paths shouldn't be resolved against the root")
+		assert.Equal(t, []string{"docs/note.md"}, iSync.Normalize())
+		assert.Equal(t, "![]("+ext+") ![](../images/a.png)", readTestFile(t, filepath.Join(root, "docs/note.md")))
+	})
+
+	t.Run("normalize", func(t *testing.T) {
+		tests := []struct {
+			style string
+			want  string
+		}{
+			{LinkStyleRoot, "![](/images/a.png)\n![](/images/b.png)\n![](/images/c.png)"},
+			{LinkStyleRelative, "![](../images/a.png)\n![](../images/b.png)\n![](../images/c.png)"},
+			{LinkStyleAbsolute, "![](ROOT/images/a.png)\n![](ROOT/images/b.png)\n![](ROOT/images/c.png)"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.style, func(t *testing.T) {
+				iSync, root := setup(t, tt.style)
+				assert.Equal(t, []string{"docs/note.md"}, iSync.Normalize())
+				want := strings.ReplaceAll(tt.want, "ROOT", filepath.ToSlash(root))
+				assert.Equal(t, want+rest, readTestFile(t, filepath.Join(root, "docs/note.md")))
+				assert.Empty(t, iSync.Normalize(), "links are already normalized")
+			})
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package syncer
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rootDir returns path to the root directory inside the file system
+func (s *LinkSyncer) rootDir() string {
+	if filepath.IsAbs(s.root) {
+		return "."
+	}
+	return filepath.ToSlash(s.root)
+}
+
+func nameKey(filePath string) string {
+	return strings.ToLower(path.Base(filePath))
+}
+
+// addName saves the file in the index of file names
+func (s *LinkSyncer) addName(filePath string) {
+	key := nameKey(filePath)
+	if s.names[key] == nil {
+		s.names[key] = map[string]Empty{}
+	}
+	s.names[key][filePath] = Empty{}
+}
+
+// removeName deletes the file from the index of file names
+func (s *LinkSyncer) removeName(filePath string) {
+	key := nameKey(filePath)
+	delete(s.names[key], filePath)
+	if len(s.names[key]) == 0 {
+		delete(s.names, key)
+	}
+}
+
+func (s *LinkSyncer) renameName(from, to string) {
+	if _, ok := s.names[nameKey(from)][from]; !ok {
+		return
+	}
+	s.removeName(from)
+	s.addName(to)
+}
+
+// renameDir updates paths of the files inside the renamed directory
+func (s *LinkSyncer) renameDir(from, to string) {
+	for key, paths := range s.names {
+		for p := range paths {
+			if newPath, ok := movedPrefix(p, from, to); ok {
+				delete(paths, p)
+				s.names[key][newPath] = Empty{}
+			}
+		}
+	}
+}
+
+func (s *LinkSyncer) hasFile(filePath string) bool {
+	_, ok := s.names[nameKey(filePath)][filePath]
+	return ok
+}
+
+// findByName looks for a file with the given name. If there are several files
+// with the same name, it prefers the one in the note's directory and then the one
+// with the shortest path.
+func (s *LinkSyncer) findByName(notePath string, name string) string {
+	noteDir := path.Dir(notePath)
+	found := ""
+	for candidate := range s.names[nameKey(name)] {
+		if path.Dir(candidate) == noteDir {
+			return candidate
+		}
+		if found == "" || len(candidate) < len(found) || (len(candidate) == len(found) && candidate < found) {
+			found = candidate
+		}
+	}
+	return found
+}
+
+// resolveWikiLinks resolves targets of wiki links against the indexed tree
+func (s *LinkSyncer) resolveWikiLinks(notePath string, links []LinkInfo) {
+	for i := range links {
+		switch links[i].wiki {
+		case wikiName:
+			if found := s.findByName(notePath, wikiFileName(links[i].path)); found != "" {
+				links[i].rootPath = found
+			}
+		case wikiRelative:
+			if s.hasFile(links[i].rootPath) {
+				continue
+			}
+			// path isn't relative to the note, try to resolve it from the root directory
+			rootPath := path.Join(s.rootDir(), wikiFileName(links[i].path))
+			if s.hasFile(rootPath) {
+				links[i].rootPath = rootPath
+				links[i].wiki = wikiAbsolute
+				links[i].wikiBase = s.rootDir()
+			}
+		}
+	}
+}